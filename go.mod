@@ -3,6 +3,7 @@ module github.com/phR0ze/chroma
 go 1.13
 
 require (
+	github.com/PuerkitoBio/goquery v1.5.0
 	github.com/phR0ze/n v1.1.17
 	github.com/pkg/errors v0.8.1
 	github.com/sirupsen/logrus v1.4.2
@@ -0,0 +1,263 @@
+package chroma
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/pkg/errors"
+)
+
+// Kinds of actions chroma performs on disk
+const (
+	ActionDownload = "download" // download a url or patch to a file
+	ActionGenerate = "generate" // generate a file from another e.g. extension preferences
+	ActionLink     = "link"     // symlink a file
+	ActionMkdir    = "mkdir"    // create a directory
+	ActionMove     = "move"     // move a file
+	ActionRemove   = "remove"   // remove a file or directory
+	ActionWrite    = "write"    // write a file e.g. the manifest
+)
+
+// Action is a single change chroma makes on disk
+type Action struct {
+	Kind string `json:"kind"`           // kind of action e.g. download
+	Set  string `json:"set,omitempty"`  // patch set of the patch being downloaded
+	Src  string `json:"src,omitempty"`  // url, upstream patch path or file the action reads from
+	Dst  string `json:"dst"`            // file or directory the action changes
+	MD5  string `json:"md5,omitempty"`  // checksum of the existing file the action expects to change
+	Data string `json:"data,omitempty"` // content of planned writes so that the plan can be applied
+}
+
+// Perform the given action by calling the given function or only record it when making no
+// changes. Performed actions are recorded in the journal so that they can be undone. Planned
+// actions are tracked so that later existence checks see the disk as it
+// would be had the actions been performed.
+func (chroma *Chroma) perform(action *Action, fn func() error) (err error) {
+	chroma.plan = append(chroma.plan, action)
+	if !chroma.dryrun {
+		if chroma.journal == nil {
+			if chroma.journal, err = loadJournal(chroma.rootDir); err != nil {
+				return
+			}
+		}
+		return chroma.journal.record(chroma.command, action, fn)
+	}
+
+	if chroma.planned == nil {
+		chroma.planned = map[string]bool{}
+	}
+
+	// Record the checksum of the existing file being changed to detect drift before applying
+	if existing := action.existing(); existing != "" && chroma.onDisk(existing) && !sys.IsDir(existing) {
+		action.MD5, _ = sys.MD5(existing)
+	}
+	switch action.Kind {
+	case ActionMove:
+		chroma.planned[action.Src] = false
+		chroma.planned[action.Dst] = true
+	case ActionRemove:
+		for target := range chroma.planned {
+			if strings.HasPrefix(target, action.Dst+"/") {
+				delete(chroma.planned, target)
+			}
+		}
+		chroma.planned[action.Dst] = false
+		chroma.removed = append(chroma.removed, action.Dst)
+	default:
+		chroma.planned[action.Dst] = true
+	}
+	return
+}
+
+// existing returns the path of the existing file the action changes if any
+func (action *Action) existing() string {
+	switch action.Kind {
+	case ActionMove:
+		return action.Src
+	case ActionDownload, ActionRemove, ActionWrite:
+		return action.Dst
+	}
+	return ""
+}
+
+// onDisk returns true if the given path exists on disk and no planned actions have changed it
+func (chroma *Chroma) onDisk(target string) bool {
+	if _, ok := chroma.planned[target]; ok {
+		return false
+	}
+	for _, dir := range chroma.removed {
+		if strings.HasPrefix(target, dir+"/") {
+			return false
+		}
+	}
+	return sys.Exists(target)
+}
+
+// exists returns true if the given path exists on disk taking planned actions into account
+func (chroma *Chroma) exists(target string) bool {
+	if !chroma.dryrun {
+		return sys.Exists(target)
+	}
+	if exists, ok := chroma.planned[target]; ok {
+		return exists
+	}
+	return chroma.onDisk(target)
+}
+
+// Create the given directory and any parents if it doesn't exist
+func (chroma *Chroma) mkdir(dir string) (err error) {
+	if chroma.exists(dir) {
+		return
+	}
+	return chroma.perform(&Action{Kind: ActionMkdir, Dst: dir}, func() (err error) {
+		_, err = sys.MkdirP(dir)
+		return
+	})
+}
+
+// Move the given file creating the destination directory if needed
+func (chroma *Chroma) move(src, dst string) (err error) {
+	return chroma.perform(&Action{Kind: ActionMove, Src: src, Dst: dst}, func() (err error) {
+		if _, err = sys.MkdirP(path.Dir(dst)); err != nil {
+			return
+		}
+		_, err = sys.Move(src, dst)
+		return
+	})
+}
+
+// Write the given data to the given file replacing any existing file and creating the directory if needed
+func (chroma *Chroma) write(dst string, data []byte) (err error) {
+	action := &Action{Kind: ActionWrite, Dst: dst}
+	if chroma.dryrun {
+		action.Data = string(data)
+	}
+	return chroma.perform(action, func() (err error) {
+		if _, err = sys.MkdirP(path.Dir(dst)); err != nil {
+			return
+		}
+		return sys.WriteBytes(dst, data)
+	})
+}
+
+// Write the given data to the given file only if it differs from the existing file
+func (chroma *Chroma) update(dst string, data []byte) (err error) {
+	if chroma.onDisk(dst) {
+		if existing, e := sys.ReadBytes(dst); e == nil && bytes.Equal(existing, data) {
+			return
+		}
+	}
+	return chroma.write(dst, data)
+}
+
+// Symlink the given destination to the given source file creating the destination directory if needed
+func (chroma *Chroma) link(src, dst string) (err error) {
+	return chroma.perform(&Action{Kind: ActionLink, Src: src, Dst: dst}, func() (err error) {
+		if _, err = sys.MkdirP(path.Dir(dst)); err != nil {
+			return
+		}
+		return os.Symlink(src, dst)
+	})
+}
+
+// Remove the given file or directory and everything in it by moving it to the journal's
+// trash so that it can be restored by undo.
+func (chroma *Chroma) remove(target string) (err error) {
+	return chroma.perform(&Action{Kind: ActionRemove, Dst: target}, func() error {
+		return chroma.journal.trash(target)
+	})
+}
+
+// Print out the actions performed or planned in order
+func (chroma *Chroma) printPlan() {
+	if len(chroma.plan) == 0 {
+		chroma.printf("Dry run, nothing to do\n")
+		return
+	}
+	chroma.printf("Dry run, the following actions would be performed:\n")
+	for i, action := range chroma.plan {
+		chroma.printf("%3d. %s\n", i+1, chroma.describe(action))
+	}
+}
+
+// describe returns a human readable description of the given action with paths relative
+// to the package root.
+func (chroma *Chroma) describe(action *Action) string {
+	rel := chroma.relPath
+	switch action.Kind {
+	case ActionDownload:
+		if action.Set != "" {
+			return fmt.Sprintf("download %s:%s => %s", action.Set, action.Src, rel(action.Dst))
+		}
+		return fmt.Sprintf("download %s => %s", action.Src, rel(action.Dst))
+	case ActionGenerate, ActionLink, ActionMove:
+		return fmt.Sprintf("%s %s => %s", action.Kind, rel(action.Src), rel(action.Dst))
+	}
+	return fmt.Sprintf("%s %s", action.Kind, rel(action.Dst))
+}
+
+// Return the directory the given patch set's order files and sync state are cached in. When
+// making no changes this is a scratch copy of the patch set's cache files so that the patch
+// sources can still download and read them without touching the package.
+func (chroma *Chroma) cacheDir(patchSetDir string) (dir string, cleanup func(), err error) {
+	if !chroma.dryrun {
+		return patchSetDir, func() {}, nil
+	}
+	return chroma.scratchCache(patchSetDir)
+}
+
+// Return a scratch copy of the given patch set's cache files e.g. order files and sync state
+// with its hidden directories e.g. cloned sources linked in.
+func (chroma *Chroma) scratchCache(patchSetDir string) (dir string, cleanup func(), err error) {
+	cleanup = func() {}
+	if dir, err = ioutil.TempDir("", "chroma"); err != nil {
+		err = errors.Wrap(err, "failed to create scratch cache directory")
+		return
+	}
+	cleanup = func() { sys.RemoveAll(dir) }
+	if !chroma.exists(patchSetDir) {
+		return
+	}
+
+	// Copy the cache files and link hidden directories e.g. cloned sources
+	var infos []os.FileInfo
+	if infos, err = ioutil.ReadDir(patchSetDir); err != nil {
+		err = errors.Wrapf(err, "failed to read patch set directory %s", patchSetDir)
+		return
+	}
+	for _, info := range infos {
+		src := path.Join(patchSetDir, info.Name())
+		switch {
+		case info.IsDir() && strings.HasPrefix(info.Name(), "."):
+			if err = os.Symlink(src, path.Join(dir, info.Name())); err != nil {
+				err = errors.Wrapf(err, "failed to link %s", src)
+				return
+			}
+		case info.Mode().IsRegular() && path.Ext(info.Name()) != ".patch":
+			if _, err = sys.CopyFile(src, path.Join(dir, info.Name())); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// Return a cache writer for the given patch set that writes changed cache files e.g. order
+// files to the patch set directory so that they are journaled or planned. When making no
+// changes the scratch copy in the given cache directory is also written for the patch
+// sources to read.
+func (chroma *Chroma) cacheWriter(cacheDir, patchSetDir string) func(dst string, data []byte) error {
+	return func(dst string, data []byte) (err error) {
+		if cacheDir != patchSetDir {
+			if err = sys.WriteBytes(dst, data); err != nil {
+				return
+			}
+		}
+		return chroma.update(path.Join(patchSetDir, path.Base(dst)), data)
+	}
+}
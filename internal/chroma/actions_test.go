@@ -0,0 +1,65 @@
+package chroma
+
+import (
+	"path"
+	"testing"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRun(t *testing.T) {
+	c, dir := newTestPackage(t, `version: 1
+patchsets:
+  team:
+    source: dir
+    url: team
+    patches:
+      - name: b.patch
+        enabled: true
+`)
+	defer sys.RemoveAll(dir)
+	teamDir := path.Join(dir, "team")
+	writeTestFiles(t, teamDir, map[string]string{
+		"series":  "a.patch\nb.patch\n",
+		"a.patch": "a",
+		"b.patch": "b",
+	})
+
+	// Nothing is changed on disk and the plan is in order
+	c.dryrun = true
+	assert.Nil(t, c.downloadPatches([]string{"team"}, &downloadOpts{}))
+	assert.False(t, sys.Exists(path.Join(dir, "patches")))
+	descriptions := []string{}
+	for _, action := range c.plan {
+		descriptions = append(descriptions, c.describe(action))
+	}
+	assert.Equal(t, []string{
+		"mkdir patches/team/not-used",
+		"download team:a.patch => patches/team/not-used/00-a.patch",
+		"download team:b.patch => patches/team/01-b.patch",
+		"write patches/team/.sync.json",
+	}, descriptions)
+
+	// Planned state is seen by later steps e.g. cleaning and downloading again
+	c.dryrun = false
+	assert.Nil(t, c.downloadPatches([]string{"team"}, &downloadOpts{}))
+	c.dryrun = true
+	c.plan = nil
+	c.manifest.PatchSets["team"].patch("b.patch").Enabled = false
+	assert.Nil(t, c.sortPatches("team", c.manifest.PatchSets["team"]))
+	assert.Nil(t, c.downloadPatches([]string{"team"}, &downloadOpts{clean: true}))
+	descriptions = []string{}
+	for _, action := range c.plan {
+		descriptions = append(descriptions, c.describe(action))
+	}
+	assert.Equal(t, []string{
+		"move patches/team/01-b.patch => patches/team/not-used/01-b.patch",
+		"remove patches/team",
+		"mkdir patches/team/not-used",
+		"download team:a.patch => patches/team/not-used/00-a.patch",
+		"download team:b.patch => patches/team/not-used/01-b.patch",
+		"write patches/team/.sync.json",
+	}, descriptions)
+	assert.True(t, sys.Exists(path.Join(dir, "patches/team/01-b.patch")))
+}
@@ -0,0 +1,287 @@
+package chroma
+
+import (
+	"path"
+
+	"github.com/phR0ze/n/pkg/net/mech"
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func (chroma *Chroma) newApplyCmd() *cobra.Command {
+	opts := &checkOpts{}
+	cmd := &cobra.Command{
+		Use:   "apply PLAN|SRC",
+		Short: "Perform a plan written by chroma plan or check the patches apply",
+		Long: `Perform the actions of a plan written by chroma plan in order. The plan is first
+checked against the disk and refused if anything it assumed has since changed e.g. a
+patch it would move is missing or was modified.
+
+With --check the enabled patches are instead applied in memory in series order to the
+given chromium source directory or tarball with offset and fuzz handling. Each patch is reported
+as applying cleanly, with fuzz or failing along with the hunks that fuzzed or failed.
+
+Examples:
+	# Apply a reviewed plan
+	chroma apply plan.json
+
+	# Check the plan still matches the disk without making changes
+	chroma apply plan.json --dry-run
+
+	# Check the debian and ungoogled patches apply to an extracted chromium source
+	chroma apply --check ~/src/chromium-76.0.3809.100
+
+	# Check only the debian patches and write the patched tree to a copy of the source
+	chroma apply --check ~/src/chromium-76.0.3809.100 --distros debian --output /tmp/chromium
+
+	# Check against the source tarball streaming out only the files the patches touch
+	chroma apply --check --tarball chromium-76.0.3809.100.tar.xz
+
+	# Check against the tarball for the PKGBUILD version next to the PKGBUILD
+	chroma apply --check
+`,
+		Args: cobra.RangeArgs(0, 1),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			if !opts.check && len(args) != 1 {
+				return errors.Errorf("Error: a plan file is required")
+			}
+			if err = chroma.configure(); err != nil {
+				return
+			}
+			if !opts.check {
+				return chroma.applyPlan(args[0])
+			}
+
+			// Check against the given source directory or tarball defaulting to the PKGBUILD's tarball
+			if len(args) == 1 {
+				var srcDir string
+				if srcDir, err = sys.Expand(args[0]); err != nil {
+					return
+				}
+				return chroma.checkSource(srcDir, opts)
+			}
+			if opts.tarball == "" {
+				opts.tarball = chroma.defaultTarball()
+			}
+			return chroma.checkTarball(opts.tarball, opts)
+		},
+	}
+	cmd.Flags().BoolVar(&opts.check, "check", false, "Check the enabled patches apply to the given chromium source")
+	cmd.Flags().StringVar(&opts.tarball, "tarball", "", "Check against this chromium source tarball, defaults to the PKGBUILD version's")
+	cmd.Flags().StringVar(&opts.output, "output", "", "Write the patched source to this copy of the source tree")
+	cmd.Flags().StringSliceVar(&opts.distros, "distros", nil, "Distributions to check the patches of in order, defaults to all downloaded")
+	return cmd
+}
+
+// planSource is a patch source used to perform the planned downloads for a patch set
+type planSource struct {
+	source  PatchSource            // source to fetch the patches from
+	paths   map[string]*PatchEntry // patches by upstream path
+	cleanup func()                 // removes the scratch copy of the cached series
+}
+
+// Apply the given plan file after checking it still matches the disk
+func (chroma *Chroma) applyPlan(filepath string) (err error) {
+	var plan *Plan
+	if plan, err = chroma.readPlan(filepath); err != nil {
+		return
+	}
+	if plan.ChromiumVer != chroma.chromiumVer {
+		err = errors.Errorf("plan was made for chromium %s but the PKGBUILD is for %s", plan.ChromiumVer, chroma.chromiumVer)
+		return
+	}
+	if err = chroma.checkPlan(plan); err != nil {
+		return
+	}
+	if chroma.dryrun {
+		return
+	}
+
+	// Resolve the planned patch downloads before performing anything
+	sources, err := chroma.planSources(plan)
+	defer func() {
+		for _, source := range sources {
+			source.cleanup()
+		}
+	}()
+	if err != nil {
+		return
+	}
+
+	// Perform the actions in order
+	for i, action := range plan.Actions {
+		log.Infof("Applying action %d/%d: %s", i+1, len(plan.Actions), chroma.describe(action))
+		if err = chroma.perform(action, func() error { return chroma.execute(action, sources) }); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Check that the given plan still matches the disk by replaying it without making changes.
+// Each action's expectations are checked against the disk as it would be at that point.
+func (chroma *Chroma) checkPlan(plan *Plan) (err error) {
+	dryrun := chroma.dryrun
+	chroma.dryrun = true
+	defer func() {
+		chroma.dryrun = dryrun
+		if !dryrun {
+			chroma.plan, chroma.planned, chroma.removed = nil, nil, nil
+		}
+	}()
+
+	for i, action := range plan.Actions {
+		if err = chroma.expect(action); err != nil {
+			err = errors.WithMessagef(err, "plan no longer matches the disk at action %d %s", i+1, chroma.describe(action))
+			return
+		}
+		if err = chroma.perform(action, nil); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Check the disk is in the state the given action expects
+func (chroma *Chroma) expect(action *Action) (err error) {
+	missing := func(target string) error { return errors.Errorf("%s doesn't exist", chroma.relPath(target)) }
+	found := func(target string) error { return errors.Errorf("%s already exists", chroma.relPath(target)) }
+
+	switch action.Kind {
+	case ActionMkdir:
+		if chroma.exists(action.Dst) {
+			return found(action.Dst)
+		}
+	case ActionMove, ActionGenerate:
+		if !chroma.exists(action.Src) {
+			return missing(action.Src)
+		}
+		if chroma.exists(action.Dst) {
+			return found(action.Dst)
+		}
+	case ActionRemove:
+		if !chroma.exists(action.Dst) {
+			return missing(action.Dst)
+		}
+	case ActionDownload, ActionWrite:
+		if action.MD5 == "" && chroma.exists(action.Dst) {
+			return found(action.Dst)
+		}
+		if action.MD5 != "" && !chroma.exists(action.Dst) {
+			return missing(action.Dst)
+		}
+	default:
+		return errors.Errorf("unsupported action kind %s", action.Kind)
+	}
+
+	// Existing files must be unchanged since the plan was made
+	if existing := action.existing(); action.MD5 != "" && chroma.onDisk(existing) {
+		if sum, _ := sys.MD5(existing); sum != action.MD5 {
+			return errors.Errorf("%s has changed", chroma.relPath(existing))
+		}
+	}
+	return
+}
+
+// Execute the given planned action using the given patch sources for patch downloads
+func (chroma *Chroma) execute(action *Action, sources map[string]*planSource) (err error) {
+	switch action.Kind {
+	case ActionMkdir:
+		_, err = sys.MkdirP(action.Dst)
+	case ActionMove:
+		if _, err = sys.MkdirP(path.Dir(action.Dst)); err != nil {
+			return
+		}
+		_, err = sys.Move(action.Src, action.Dst)
+	case ActionRemove:
+		err = chroma.journal.trash(action.Dst)
+	case ActionGenerate:
+		err = generateExtensionPrefs(action.Src, action.Dst)
+	case ActionWrite:
+		if _, err = sys.MkdirP(path.Dir(action.Dst)); err != nil {
+			return
+		}
+		err = sys.WriteBytes(action.Dst, []byte(action.Data))
+	case ActionDownload:
+		if action.Set == "" {
+			_, err = mech.New().Download(action.Src, action.Dst)
+			return
+		}
+		source := sources[action.Set]
+		err = source.source.Fetch(source.paths[action.Src], action.Dst)
+	}
+	return
+}
+
+// Create the patch sources for the given plan's patch downloads from the series cached when
+// the plan was made i.e. the cached order files overlaid with the plan's writes of them. The
+// series isn't revalidated upstream and the plan is refused if any of its patches are missing.
+func (chroma *Chroma) planSources(plan *Plan) (sources map[string]*planSource, err error) {
+	sources = map[string]*planSource{}
+	for i, action := range plan.Actions {
+		if action.Kind != ActionDownload || action.Set == "" {
+			continue
+		}
+		source := sources[action.Set]
+		if source == nil {
+			source, err = chroma.newPlanSource(plan, action.Set)
+			if source != nil {
+				sources[action.Set] = source
+			}
+			if err != nil {
+				return
+			}
+		}
+		if source.paths[action.Src] == nil {
+			err = errors.Errorf("plan no longer matches the series at action %d %s: %s isn't in the %s series",
+				i+1, chroma.describe(action), action.Src, action.Set)
+			return
+		}
+	}
+	return
+}
+
+// Create the patch source for the given distribution's patch set from the given plan
+func (chroma *Chroma) newPlanSource(plan *Plan, distro string) (source *planSource, err error) {
+	set, ok := chroma.manifest.PatchSets[distro]
+	if !ok {
+		err = errors.Errorf("Error: unsupported patch set %s", distro)
+		return
+	}
+	patchSetDir := path.Join(chroma.patchesDir, distro)
+	source = &planSource{paths: map[string]*PatchEntry{}}
+	var cacheDir string
+	if cacheDir, source.cleanup, err = chroma.scratchCache(patchSetDir); err != nil {
+		return
+	}
+	for _, action := range plan.Actions {
+		if action.Kind == ActionWrite && path.Dir(action.Dst) == patchSetDir {
+			if err = sys.WriteBytes(path.Join(cacheDir, path.Base(action.Dst)), []byte(action.Data)); err != nil {
+				return
+			}
+		}
+	}
+
+	// Read the series without revalidating it then always download the planned patches
+	var state *syncState
+	if state, err = loadSyncState(cacheDir); err != nil {
+		return
+	}
+	fetcher := newFetcher(state, false)
+	opts := &PatchSourceOpts{Set: set, PatchSetDir: cacheDir, RootDir: chroma.rootDir, fetcher: fetcher}
+	if source.source, err = newPatchSource(plan.Sources[distro], opts); err != nil {
+		err = errors.WithMessagef(err, "failed to create patch source for %s", distro)
+		return
+	}
+	var entries []*PatchEntry
+	if entries, err = source.source.Entries(); err != nil {
+		return
+	}
+	for _, entry := range entries {
+		source.paths[entry.Path] = entry
+	}
+	fetcher.state, fetcher.revalidate = &syncState{Files: map[string]*cacheInfo{}}, true
+	return
+}
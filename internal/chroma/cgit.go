@@ -0,0 +1,214 @@
+package chroma
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/phR0ze/n/pkg/net/mech"
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	gRXCgitCommitID = regexp.MustCompile(`[?&]id=([0-9a-f]{7,40})`)
+	gRXCgitSlug     = regexp.MustCompile(`[^A-Za-z0-9_.]+`)
+)
+
+// cgitSource provides the commits of a cgit branch as patches
+type cgitSource struct {
+	uri         string   // url of the cgit branch commit page
+	patchSetDir string   // local patch set directory to cache the commit series in
+	fetcher     *fetcher // fetcher to download with
+}
+
+func newCgitSource(opts *PatchSourceOpts) (PatchSource, error) {
+	return &cgitSource{uri: opts.Set.URL, patchSetDir: opts.PatchSetDir, fetcher: opts.fetcher}, nil
+}
+
+// Entries returns the branch's commits in apply order named as git format-patch would
+func (source *cgitSource) Entries() (entries []*PatchEntry, err error) {
+	var commits []*cgitCommit
+	if commits, err = readCgitOrderFile(source.fetcher, source.uri, source.patchSetDir); err != nil {
+		return
+	}
+	for _, commit := range commits {
+		entries = append(entries, &PatchEntry{Path: commit.Name(), Strip: DefaultStrip, ref: commit.ID})
+	}
+	return
+}
+
+// Fetch downloads the given commit as a patch
+func (source *cgitSource) Fetch(entry *PatchEntry, dst string) (err error) {
+	_, err = source.fetcher.fetch(cgitPatchURL(source.uri, &cgitCommit{ID: entry.ref}), dst)
+	return
+}
+
+// cgitCommit is a single commit in a cgit commit series
+type cgitCommit struct {
+	ID      string // commit hash
+	Subject string // commit subject line
+}
+
+// Name returns the patch file name git format-patch would generate for the commit
+func (commit *cgitCommit) Name() string {
+	slug := strings.Trim(gRXCgitSlug.ReplaceAllString(commit.Subject, "-"), "-.")
+	if len(slug) > 52 {
+		slug = strings.TrimRight(slug[:52], "-.")
+	}
+	return fmt.Sprintf("%s.patch", slug)
+}
+
+// Read the commit series for the given cgit branch page from disk, scraping it if it doesn't exist
+// or a refresh was requested. The series is every commit on the branch down to the first tagged
+// commit which is the upstream chromium release the patches are based on. The series is returned
+// in apply order.
+func readCgitOrderFile(fetcher *fetcher, uri, patchSetDir string) (commits []*cgitCommit, err error) {
+
+	// Scrape the commits and write out the order file if needed
+	orderFile := path.Join(patchSetDir, "commits")
+	if fetcher.revalidate || !sys.Exists(orderFile) {
+		log.Infof("Scraping commit series %s", uri)
+		if commits, err = scrapeCgitCommits(uri); err != nil {
+			return
+		}
+		lines := []string{}
+		for _, commit := range commits {
+			lines = append(lines, fmt.Sprintf("%s %s", commit.ID, commit.Subject))
+		}
+		err = fetcher.save(orderFile, []byte(strings.Join(lines, "\n")))
+		return
+	}
+
+	// Read in the order file
+	var data []string
+	if data, err = sys.ReadLines(orderFile); err != nil {
+		return
+	}
+	for i, line := range data {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			err = errors.Errorf("malformed commit on line %d of %s", i+1, orderFile)
+			return
+		}
+		commits = append(commits, &cgitCommit{ID: fields[0], Subject: fields[1]})
+	}
+	return
+}
+
+// Walk the cgit log pages for the given branch page collecting commits until the base is found
+func scrapeCgitCommits(uri string) (commits []*cgitCommit, err error) {
+	next := cgitURL(uri, "log")
+	for next != "" {
+		var reader io.ReadCloser
+		if reader, err = mech.Stream(next); err != nil {
+			return
+		}
+		var page []*cgitCommit
+		var more string
+		page, more, err = parseCgitLog(reader)
+		reader.Close()
+		if err != nil {
+			return
+		}
+		commits = append(commits, page...)
+
+		// Follow the pager only if the base wasn't found on this page
+		next = ""
+		if more != "" {
+			if next, err = resolveURL(uri, more); err != nil {
+				return
+			}
+		}
+	}
+	if len(commits) == 0 {
+		err = errors.Errorf("no commits found for %s", uri)
+		return
+	}
+
+	// Log pages list newest first, patches apply oldest first
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+	return
+}
+
+// Parse a single cgit log page returning the commits newest first up to the first tagged commit
+// and the link to the next page if the tagged commit wasn't found on this page.
+func parseCgitLog(reader io.Reader) (commits []*cgitCommit, next string, err error) {
+	var doc *goquery.Document
+	if doc, err = goquery.NewDocumentFromReader(reader); err != nil {
+		err = errors.Wrap(err, "failed to parse cgit log page")
+		return
+	}
+
+	base := false
+	doc.Find("table.list tr").EachWithBreak(func(i int, row *goquery.Selection) bool {
+		link := row.Find("td a[href*='/commit/']").First()
+		href, ok := link.Attr("href")
+		if !ok {
+			return true
+		}
+		if row.Find("span.decoration a.tag-deco").Length() > 0 {
+			base = true
+			return false
+		}
+		match := gRXCgitCommitID.FindStringSubmatch(href)
+		if match == nil {
+			return true
+		}
+		commits = append(commits, &cgitCommit{ID: match[1], Subject: strings.TrimSpace(link.Text())})
+		return true
+	})
+
+	// Only continue to the next page if we haven't found the base
+	if !base {
+		doc.Find("ul.pager a").Each(func(i int, link *goquery.Selection) {
+			if strings.Contains(link.Text(), "next") {
+				next, _ = link.Attr("href")
+			}
+		})
+	}
+	return
+}
+
+// Convert the given cgit page url into the url for the given cgit page type
+// e.g. https://host/cgit.cgi/repo/commit/?h=branch => https://host/cgit.cgi/repo/log/?h=branch
+func cgitURL(uri, page string) string {
+	if i := strings.Index(uri, "/commit/"); i != -1 {
+		return uri[:i] + "/" + page + "/" + uri[i+len("/commit/"):]
+	}
+	return uri
+}
+
+// Build the url to download the given commit as a patch from the given cgit page url
+func cgitPatchURL(uri string, commit *cgitCommit) string {
+	patchURL := cgitURL(uri, "patch")
+	if i := strings.Index(patchURL, "?"); i != -1 {
+		patchURL = patchURL[:i]
+	}
+	return fmt.Sprintf("%s?id=%s", patchURL, commit.ID)
+}
+
+// Resolve the given possibly relative reference against the given base url
+func resolveURL(base, ref string) (result string, err error) {
+	var baseURL, refURL *url.URL
+	if baseURL, err = url.Parse(base); err != nil {
+		err = errors.Wrapf(err, "failed to parse url %s", base)
+		return
+	}
+	if refURL, err = url.Parse(ref); err != nil {
+		err = errors.Wrapf(err, "failed to parse url %s", ref)
+		return
+	}
+	result = baseURL.ResolveReference(refURL).String()
+	return
+}
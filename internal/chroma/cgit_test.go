@@ -0,0 +1,35 @@
+package chroma
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCgitLog(t *testing.T) {
+	file, err := os.Open("testdata/iridium-log.html")
+	assert.Nil(t, err)
+	defer file.Close()
+
+	// Commits stop at the tagged upstream release and the pager isn't followed
+	commits, next, err := parseCgitLog(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "", next)
+	assert.Equal(t, 4, len(commits))
+	assert.Equal(t, "6f0d2e8c1d35a2f1c0b3c5c99d2a1e0a9b7b3e41", commits[0].ID)
+	assert.Equal(t, "browser-disable-profile-auto-import-on-first-run.patch", commits[0].Name())
+	assert.Equal(t, "net-cert-increase-default-key-length-for-newly-gener.patch", commits[2].Name())
+	assert.Equal(t, "safe_browsing-disable-incident-reporting.patch", commits[3].Name())
+}
+
+func TestCgitURLs(t *testing.T) {
+	uri := gPatchSets["iridium"].URL
+	assert.Equal(t, "https://git.iridiumbrowser.de/cgit.cgi/iridium-browser/log/?h=patchview", cgitURL(uri, "log"))
+	assert.Equal(t, "https://git.iridiumbrowser.de/cgit.cgi/iridium-browser/patch/?id=abc1234",
+		cgitPatchURL(uri, &cgitCommit{ID: "abc1234"}))
+
+	next, err := resolveURL(cgitURL(uri, "log"), "/cgit.cgi/iridium-browser/log/?h=patchview&ofs=50")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://git.iridiumbrowser.de/cgit.cgi/iridium-browser/log/?h=patchview&ofs=50", next)
+}
@@ -0,0 +1,358 @@
+package chroma
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Outcomes of checking a patch against the chromium source
+const (
+	PatchClean  = "clean"  // every hunk applied exactly possibly at an offset
+	PatchFuzz   = "fuzz"   // every hunk applied but some ignored context lines
+	PatchFailed = "failed" // some hunks didn't apply
+)
+
+type checkOpts struct {
+	check   bool     // check the patches apply rather than applying a plan
+	tarball string   // chromium source tarball to check the patches against
+	output  string   // directory to write a patched copy of the source tree to
+	distros []string // distributions to check the patches of in order
+}
+
+// sourceTree provides the original content of chromium source files
+type sourceTree interface {
+
+	// ReadFile returns the content of the given file relative to the tree root if it exists
+	ReadFile(name string) (data []byte, ok bool, err error)
+}
+
+// dirTree is a chromium source tree extracted to a directory
+type dirTree struct {
+	dir string // root of the source tree
+}
+
+// ReadFile returns the content of the given file relative to the tree root if it exists
+func (tree *dirTree) ReadFile(name string) (data []byte, ok bool, err error) {
+	target := path.Join(tree.dir, name)
+	if !sys.Exists(target) {
+		return
+	}
+	if data, err = ioutil.ReadFile(target); err != nil {
+		err = errors.Wrapf(err, "failed to read source file %s", target)
+		return
+	}
+	ok = true
+	return
+}
+
+// treeFile is the content of a source file as lines
+type treeFile struct {
+	lines []string // lines without line endings
+	noEOL bool     // true if the last line has no newline
+}
+
+// newTreeFile splits the given content into lines
+func newTreeFile(data []byte) *treeFile {
+	file := &treeFile{}
+	if len(data) == 0 {
+		return file
+	}
+	content := string(data)
+	if !strings.HasSuffix(content, "\n") {
+		file.noEOL = true
+	} else {
+		content = content[:len(content)-1]
+	}
+	file.lines = strings.Split(content, "\n")
+	return file
+}
+
+// Bytes returns the content of the file
+func (file *treeFile) Bytes() []byte {
+	if len(file.lines) == 0 {
+		return []byte{}
+	}
+	content := strings.Join(file.lines, "\n")
+	if !file.noEOL {
+		content += "\n"
+	}
+	return []byte(content)
+}
+
+// patchedTree is the changes made by applied patches on top of a source tree
+type patchedTree struct {
+	base  sourceTree           // original source tree
+	files map[string]*treeFile // changed files by name, nil for deleted files
+}
+
+func newPatchedTree(base sourceTree) *patchedTree {
+	return &patchedTree{base: base, files: map[string]*treeFile{}}
+}
+
+// read returns the current content of the given file if it exists
+func (tree *patchedTree) read(name string) (file *treeFile, ok bool, err error) {
+	if file, changed := tree.files[name]; changed {
+		return file, file != nil, nil
+	}
+	var data []byte
+	if data, ok, err = tree.base.ReadFile(name); err != nil || !ok {
+		return
+	}
+	file = newTreeFile(data)
+	return
+}
+
+// PatchReport is the outcome of checking a single patch
+type PatchReport struct {
+	Patch  *seriesPatch // patch checked
+	Status string       // clean, fuzz or failed
+	Notes  []string     // fuzzed or failed hunks and other problems
+}
+
+// Check the given patch applies on top of the given tree and apply it if it does. A patch
+// is only applied if all of its hunks apply like makepkg would require.
+func checkPatch(tree *patchedTree, patch *seriesPatch) (report *PatchReport, err error) {
+	report = &PatchReport{Patch: patch, Status: PatchClean}
+	fail := func(format string, a ...interface{}) {
+		report.Status = PatchFailed
+		report.Notes = append(report.Notes, fmt.Sprintf(format, a...))
+	}
+
+	var diffs []*FileDiff
+	if diffs, err = patch.diffs(); err != nil {
+		return
+	}
+	if len(diffs) == 0 {
+		fail("no file changes found")
+		return
+	}
+
+	changes := map[string]*treeFile{}
+	for _, diff := range diffs {
+		name := diff.Name(patch.Strip)
+		if diff.Binary {
+			fail("%s: binary changes can't be checked", name)
+			continue
+		}
+
+		// Read the file as changed by earlier diffs in this patch or the tree
+		file, seen := changes[name]
+		ok := file != nil
+		if !seen {
+			if file, ok, err = tree.read(name); err != nil {
+				return
+			}
+		}
+		switch {
+		case diff.Created() && ok && len(file.lines) > 0:
+			fail("%s: file to create already exists", name)
+			continue
+		case !diff.Created() && !ok:
+			fail("%s: file doesn't exist", name)
+			continue
+		case !ok:
+			file = &treeFile{}
+		}
+
+		// Apply the hunks noting any that fuzzed or failed
+		lines, results := applyHunks(file.lines, diff.Hunks)
+		noEOL := file.noEOL
+		for i, result := range results {
+			switch {
+			case !result.Applied:
+				fail("%s: hunk %d %s failed", name, i+1, result.Hunk.Header())
+			case result.Fuzz > 0:
+				if report.Status == PatchClean {
+					report.Status = PatchFuzz
+				}
+				report.Notes = append(report.Notes, fmt.Sprintf("%s: hunk %d %s applied with fuzz %d offset %d",
+					name, i+1, result.Hunk.Header(), result.Fuzz, result.Offset))
+			}
+			if result.Hunk.NewNoEOL {
+				noEOL = true
+			} else if result.Hunk.OldNoEOL {
+				noEOL = false
+			}
+		}
+		if diff.Deleted() {
+			changes[name] = nil
+		} else {
+			changes[name] = &treeFile{lines: lines, noEOL: noEOL}
+		}
+	}
+
+	// Only apply the patch if it applies completely
+	if report.Status != PatchFailed {
+		for name, file := range changes {
+			tree.files[name] = file
+		}
+	}
+	return
+}
+
+// Check the enabled patches of the given distributions apply in order on top of the given tree
+func (chroma *Chroma) checkPatches(tree *patchedTree, distros []string) (reports []*PatchReport, err error) {
+	var patches []*seriesPatch
+	if patches, err = chroma.combinedPatches(distros); err != nil {
+		return
+	}
+	for _, patch := range patches {
+		var report *PatchReport
+		if report, err = checkPatch(tree, patch); err != nil {
+			return
+		}
+		reports = append(reports, report)
+	}
+	return
+}
+
+// Check the enabled patches apply to the given chromium source directory and optionally
+// write the patched tree to a copy of the source directory.
+func (chroma *Chroma) checkSource(srcDir string, opts *checkOpts) (err error) {
+	if !sys.IsDir(srcDir) {
+		err = errors.Errorf("chromium source directory %s doesn't exist", srcDir)
+		return
+	}
+	log.Infof("Checking patches against %s", srcDir)
+	return chroma.checkTree(&dirTree{dir: srcDir}, srcDir, opts)
+}
+
+// Check the enabled patches apply to the given source tree and optionally write the patched
+// files out. The output is a copy of the given source directory if set else only the changed files.
+func (chroma *Chroma) checkTree(source sourceTree, srcDir string, opts *checkOpts) (err error) {
+	tree := newPatchedTree(source)
+	var reports []*PatchReport
+	if reports, err = chroma.checkPatches(tree, opts.distros); err != nil {
+		return
+	}
+	failed := chroma.printCheckReports(reports)
+
+	if opts.output != "" {
+		log.Infof("Writing patched source => %s", opts.output)
+		if err = chroma.writePatchedTree(tree, srcDir, opts.output); err != nil {
+			return
+		}
+	}
+	if failed > 0 {
+		err = errors.Errorf("%d of %d patches failed to apply", failed, len(reports))
+	}
+	return
+}
+
+// Print out the given reports returning the number of failed patches
+func (chroma *Chroma) printCheckReports(reports []*PatchReport) (failed int) {
+	counts := map[string]int{}
+	for _, report := range reports {
+		counts[report.Status]++
+		chroma.printf("%-7s %s\n", report.Status, report.Patch)
+		for _, note := range report.Notes {
+			chroma.printf("          %s\n", note)
+		}
+	}
+	chroma.printf("\n%d clean, %d fuzz, %d failed\n", counts[PatchClean], counts[PatchFuzz], counts[PatchFailed])
+	return counts[PatchFailed]
+}
+
+// Write the given patched tree to the given output directory as a copy of the given source
+// directory if set else only the changed files. A copy is a single action generating the whole
+// directory while changed files are each written or removed.
+func (chroma *Chroma) writePatchedTree(tree *patchedTree, srcDir, output string) (err error) {
+	if srcDir != "" {
+		if chroma.exists(output) {
+			err = errors.Errorf("output directory %s already exists", output)
+			return
+		}
+		return chroma.perform(&Action{Kind: ActionGenerate, Src: srcDir, Dst: output}, func() (err error) {
+			if err = copyTree(srcDir, output); err != nil {
+				return
+			}
+			return tree.write(output)
+		})
+	}
+
+	// Existing files are removed first to leave any hard links to them untouched
+	names := []string{}
+	for name := range tree.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		target := path.Join(output, name)
+		if chroma.exists(target) {
+			if err = chroma.remove(target); err != nil {
+				return
+			}
+		}
+		if file := tree.files[name]; file != nil {
+			if err = chroma.write(target, file.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// Write the changed files to the given directory. Changed files are written as new files
+// so that hard links to the original source are left untouched.
+func (tree *patchedTree) write(dir string) (err error) {
+	for name, file := range tree.files {
+		target := path.Join(dir, name)
+		if err = os.RemoveAll(target); err != nil {
+			err = errors.Wrapf(err, "failed to remove %s", target)
+			return
+		}
+		if file == nil {
+			continue
+		}
+		if _, err = sys.MkdirP(path.Dir(target)); err != nil {
+			return
+		}
+		if err = sys.WriteBytes(target, file.Bytes()); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Copy the given directory tree to the given destination hard linking files where possible
+// to avoid duplicating the chromium source.
+func copyTree(src, dst string) (err error) {
+	if sys.Exists(dst) {
+		err = errors.Errorf("output directory %s already exists", dst)
+		return
+	}
+	err = filepath.Walk(src, func(target string, info os.FileInfo, e error) (err error) {
+		if e != nil {
+			return e
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(target, src), "/")
+		out := path.Join(dst, rel)
+		switch {
+		case info.IsDir():
+			err = os.MkdirAll(out, info.Mode().Perm()|0700)
+		case info.Mode()&os.ModeSymlink != 0:
+			var link string
+			if link, err = os.Readlink(target); err == nil {
+				err = os.Symlink(link, out)
+			}
+		default:
+			if err = os.Link(target, out); err != nil {
+				_, err = sys.CopyFile(target, out)
+			}
+		}
+		return
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed to copy %s to %s", src, dst)
+	}
+	return
+}
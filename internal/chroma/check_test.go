@@ -0,0 +1,140 @@
+package chroma
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSource(t *testing.T) {
+	c, dir := newTestPackage(t, "")
+	defer sys.RemoveAll(dir)
+	srcDir := path.Join(dir, "src", "chromium")
+	writeTestFiles(t, srcDir, map[string]string{
+		"chrome/app.cc":  "one\ntwo\nthree\nfour\nfive\n",
+		"chrome/keep.cc": "keep\n",
+	})
+	writeTestFiles(t, path.Join(dir, "patches", "debian"), map[string]string{
+		"00-app.patch": "--- a/chrome/app.cc\n+++ b/chrome/app.cc\n@@ -3,3 +3,3 @@\n three\n-four\n+FOUR\n five\n",
+		"01-new.patch": "--- /dev/null\n+++ b/chrome/new.h\n@@ -0,0 +1 @@\n+new\n",
+		// Fails as it expects the original app.cc which the first patch changed
+		"02-bad.patch":          "--- a/chrome/app.cc\n+++ b/chrome/app.cc\n@@ -4 +4 @@\n-four\n+4\n",
+		"not-used/03-off.patch": "--- a/chrome/app.cc\n+++ b/chrome/app.cc\n@@ -1 +1 @@\n-one\n+1\n",
+	})
+
+	tree := newPatchedTree(&dirTree{dir: srcDir})
+	reports, err := c.checkPatches(tree, []string{"debian"})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(reports))
+	assert.Equal(t, PatchClean, reports[0].Status)
+	assert.Equal(t, PatchClean, reports[1].Status)
+	assert.Equal(t, PatchFailed, reports[2].Status)
+	assert.Equal(t, []string{"chrome/app.cc: hunk 1 @@ -4,1 +4,1 @@ failed"}, reports[2].Notes)
+
+	// Nothing is written when making no changes
+	output := path.Join(dir, "out")
+	c.dryrun = true
+	err = c.checkSource(srcDir, &checkOpts{output: output, distros: []string{"debian"}})
+	assert.Equal(t, "1 of 3 patches failed to apply", err.Error())
+	assert.False(t, sys.Exists(output))
+	assert.Equal(t, []*Action{{Kind: ActionGenerate, Src: srcDir, Dst: output}}, c.plan)
+	c.dryrun, c.plan, c.planned = false, nil, nil
+
+	// The patched copy leaves the source untouched
+	err = c.checkSource(srcDir, &checkOpts{output: output, distros: []string{"debian"}})
+	assert.Equal(t, "1 of 3 patches failed to apply", err.Error())
+	data, _ := sys.ReadString(path.Join(output, "chrome/app.cc"))
+	assert.Equal(t, "one\ntwo\nthree\nFOUR\nfive\n", data)
+	data, _ = sys.ReadString(path.Join(output, "chrome/new.h"))
+	assert.Equal(t, "new\n", data)
+	data, _ = sys.ReadString(path.Join(output, "chrome/keep.cc"))
+	assert.Equal(t, "keep\n", data)
+	data, _ = sys.ReadString(path.Join(srcDir, "chrome/app.cc"))
+	assert.Equal(t, "one\ntwo\nthree\nfour\nfive\n", data)
+}
+
+func TestCheckTarball(t *testing.T) {
+	c, dir := newTestPackage(t, "")
+	defer sys.RemoveAll(dir)
+	writeTestFiles(t, path.Join(dir, "patches", "debian"), map[string]string{
+		"00-app.patch": "--- a/chrome/app.cc\n+++ b/chrome/app.cc\n@@ -1 +1 @@\n-one\n+1\n",
+	})
+
+	// Write a tarball with the chromium top level directory
+	tarball := path.Join(dir, "chromium-76.0.3809.100.tar.gz")
+	file, err := os.Create(tarball)
+	assert.Nil(t, err)
+	gz := gzip.NewWriter(file)
+	archive := tar.NewWriter(gz)
+	for name, data := range map[string]string{"chrome/app.cc": "one\n", "chrome/other.cc": "other\n"} {
+		assert.Nil(t, archive.WriteHeader(&tar.Header{Name: "chromium-76.0.3809.100/" + name, Mode: 0644, Size: int64(len(data))}))
+		_, err = archive.Write([]byte(data))
+		assert.Nil(t, err)
+	}
+	for name, target := range map[string]string{"chrome/linked.cc": "chrome/other.cc", "chrome/app-link.cc": "chrome/app.cc"} {
+		assert.Nil(t, archive.WriteHeader(&tar.Header{Name: "chromium-76.0.3809.100/" + name, Typeflag: tar.TypeLink,
+			Linkname: "chromium-76.0.3809.100/" + target, Mode: 0644}))
+	}
+	assert.Nil(t, archive.Close())
+	assert.Nil(t, gz.Close())
+	assert.Nil(t, file.Close())
+
+	// Only the touched files are read
+	tree, err := readTarball(tarball, map[string]bool{"chrome/app.cc": true})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"chrome/app.cc": []byte("one\n")}, tree.files)
+
+	// Hard links are resolved to their targets whether or not the target was read
+	tree, err = readTarball(tarball, map[string]bool{"chrome/app.cc": true, "chrome/app-link.cc": true, "chrome/linked.cc": true})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"chrome/app.cc": []byte("one\n"), "chrome/app-link.cc": []byte("one\n"),
+		"chrome/linked.cc": []byte("other\n")}, tree.files)
+
+	// Nothing is written when making no changes
+	output := path.Join(dir, "out")
+	c.dryrun = true
+	assert.Nil(t, c.checkTarball(tarball, &checkOpts{output: output}))
+	assert.False(t, sys.Exists(output))
+	assert.Equal(t, 1, len(c.plan))
+	assert.Equal(t, "write out/chrome/app.cc", c.describe(c.plan[0]))
+	c.dryrun, c.plan, c.planned = false, nil, nil
+
+	// Output is only the changed files
+	assert.Nil(t, c.checkTarball(tarball, &checkOpts{output: output}))
+	data, _ := sys.ReadString(path.Join(output, "chrome/app.cc"))
+	assert.Equal(t, "1\n", data)
+	assert.False(t, sys.Exists(path.Join(output, "chrome/other.cc")))
+
+	// The default tarball is the PKGBUILD version's
+	assert.Equal(t, path.Join(dir, "chromium-76.0.3809.100.tar.xz"), c.defaultTarball())
+}
+
+func TestCheckReversed(t *testing.T) {
+	c, dir := newTestPackage(t, "")
+	defer sys.RemoveAll(dir)
+	srcDir := path.Join(dir, "src", "chromium")
+	writeTestFiles(t, srcDir, map[string]string{"chrome/app.cc": "one\nTWO\nthree\n"})
+	writeTestFiles(t, path.Join(dir, "patches", "debian"), map[string]string{
+		".sync.json":      `{"series":["revert.patch"],"reversed":["revert.patch"]}`,
+		"00-revert.patch": "--- a/chrome/app.cc\n+++ b/chrome/app.cc\n@@ -1,3 +1,3 @@\n one\n-two\n+TWO\n three\n",
+	})
+
+	// Reversed series entries undo their changes
+	state := &syncState{}
+	state.record([]*PatchEntry{{Path: "revert.patch", Strip: 1, Reverse: true}})
+	assert.Equal(t, []string{"revert.patch"}, state.Reversed)
+	patches, err := c.enabledPatches("debian")
+	assert.Nil(t, err)
+	assert.True(t, patches[0].Reverse)
+	assert.Equal(t, "debian/00-revert.patch -R\n", string(formatSeries(patches)))
+
+	output := path.Join(dir, "out")
+	assert.Nil(t, c.checkSource(srcDir, &checkOpts{output: output, distros: []string{"debian"}}))
+	data, _ := sys.ReadString(path.Join(output, "chrome/app.cc"))
+	assert.Equal(t, "one\ntwo\nthree\n", data)
+}
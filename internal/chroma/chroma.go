@@ -4,24 +4,18 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strings"
 
 	"github.com/phR0ze/n"
 	"github.com/phR0ze/n/pkg/futil"
 	"github.com/phR0ze/n/pkg/opt"
 	"github.com/phR0ze/n/pkg/sys"
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
-// Distros defines the supported distribution patch sets
-type Distros struct {
-	debian    string
-	ungoogled string
-}
-
 var (
-	gDistros = Distros{"debian", "ungoogled"}
-
 	// Supported extensions
 	gExtensions = map[string]string{
 		// "scriptsafe":          "oiigbmnaadbkfbmpbfijlflahbdbdgdf", //
@@ -34,202 +28,241 @@ var (
 		"videodownload-helper": "lmjnegcaeklhafolokijcfjliaokphfk", // Video download helper for Chromium
 	}
 
-	// Order files for supported patch sets
-	gPatchSets = map[string]string{
-		"debian":    "https://salsa.debian.org/chromium-team/chromium/raw/master/debian/patches/series",
-		"ungoogled": "https://raw.githubusercontent.com/Eloston/ungoogled-chromium/master/patches/series",
-		"inox":      "https://github.com/gcarq/inox-patchset",
-		"iridium":   "https://git.iridiumbrowser.de/cgit.cgi/iridium-browser/commit/?h=patchview",
+	// Patch sets used when none are given in apply order
+	gDefaultDistros = []string{"debian", "ungoogled"}
+
+	// Sources for supported patch sets
+	gPatchSets = map[string]*PatchSet{
+		"debian":    {Source: "quilt", URL: "https://salsa.debian.org/chromium-team/chromium/raw/master/debian/patches/series"},
+		"ungoogled": {Source: "quilt", URL: "https://raw.githubusercontent.com/Eloston/ungoogled-chromium/master/patches/series"},
+		"inox":      {Source: "github", URL: "https://github.com/gcarq/inox-patchset"},
+		"iridium":   {Source: "cgit", URL: "https://git.iridiumbrowser.de/cgit.cgi/iridium-browser/commit/?h=patchview"},
+	}
+
+	// Rules classifying patches not called out individually
+	gRules = map[string][]*Rule{
+		"debian": {
+			{Match: "system/*", Enabled: false, Reason: "System: avoid rebuilding chromium every time a system lib gets updated", Tags: []string{"system-libs"}},
+		},
 	}
 
 	// Call out patches used and not used and notes
 	// Order is significant
 	// --------------------------------------------------------------------------
-	gPatches = map[string]map[string]bool{
+	gPatches = map[string][]*Patch{
 
 		// Credit to Michael Gilber
-		gDistros.debian: {
-			"00-manpage.patch":                  true,  // Adds simple doc with link to documentation website
-			"01-sandbox.patch":                  false, // Debian specific error message to install chromium-sandbox
-			"02-master-preferences.patch":       true,  // Look for master preferences in /etc/chromium/master_preferences
-			"03-libcxx.patch":                   true,  // Avoid chromium's embedded C++ library when bootstrapping
-			"04-parallel.patch":                 true,  // Respect specified number of parllel jobs when bootstrapping
-			"05-gcc_skcms_ice.patch":            true,  // GCC ICE with optimized version
-			"06-pffffft-buildfix.patch":         true,  // ??
-			"07-skia-aarch64-buildfix.patch":    true,  // ??
-			"08-wrong-namespace.patch":          false, // gcc: not using as getting inspector protocol errors
-			"09-virtual-destructor.patch":       true,  // gcc: a virtual destructor is called without this patch
-			"10-explicit-specialization.patch":  true,  // gcc: fix for gcc explicit specialiazation namespace issue
-			"11-macro.patch":                    false, // gcc6: can be ignored as arch linux is using gcc 9
-			"12-sizet.patch":                    false, // gcc6: can be ignored as arch linux is using gcc 9
-			"13-atomic.patch":                   false, // gcc6: can be ignored as arch linux is using gcc 9
-			"14-constexpr.patch":                false, // gcc6: can be ignored as arch linux is using gcc 9
-			"15-wtf-hashmap.patch":              false, // gcc6: can be ignored as arch linux is using gcc 9
-			"16-lambda-this.patch":              false, // gcc6: can be ignored as arch linux is using gcc 9
-			"17-map-insertion.patch":            false, // gcc6: can be ignored as arch linux is using gcc 9
-			"18-not-constexpr.patch":            false, // gcc6: can be ignored as arch linux is using gcc 9
-			"19-move-required.patch":            false, // gcc6: can be ignored as arch linux is using gcc 9
-			"20-use-after-move.patch":           false, // gcc6: can be ignored as arch linux is using gcc 9
-			"21-ambiguous-overloads.patch":      false, // gcc6: can be ignored as arch linux is using gcc 9
-			"22-ambiguous-initializer.patch":    false, // gcc6: can be ignored as arch linux is using gcc 9
-			"23-nullptr-copy-construct.patch":   false, // gcc6: can be ignored as arch linux is using gcc 9
-			"24-noexcept-redeclaration.patch":   false, // gcc6: can be ignored as arch linux is using gcc 9
-			"25-trivially-constructible.patch":  false, // gcc6: can be ignored as arch linux is using gcc 9
-			"26-designated-initializers.patch":  false, // gcc6: can be ignored as arch linux is using gcc 9
-			"27-specialization-namespace.patch": false, // gcc6: can be ignored as arch linux is using gcc 9
-			"28-mojo.patch":                     true,  // Fixes: fix mojo layout test build error
-			"29-public.patch":                   true,  // Fixes: method needs to be public
-			"30-ps-print.patch":                 true,  // Fixes: add postscript(ps) printing capabiliy
-			"31-as-needed.patch":                true,  // Fixes: some libraries fail to link when '--as-needed' is set
-			"32-inspector.patch":                false, // Fixes: not using as getting inspector protocol errors
-			"33-gpu-timeout.patch":              true,  // Fixes: increase GPU timeout from 10sec to 20sec
-			"34-empty-array.patch":              true,  // Fixes: arraysize macro fails for zero length array and add one char
-			"35-safebrowsing.patch":             false, // Fixes: not needed as were building with clang
-			"36-sequence-point.patch":           true,  // Fixes: fix undefined order in which expressions are evaluated
-			"37-jumbo-namespace.patch":          true,  // Fixes: jumbo build has trouble with these namespaces
-			"38-template-export.patch":          true,  // Fixes: implementation of template function must be in header to be exported
-			"39-widevine-revision.patch":        true,  // Fixes: set widevine version as undefined
-			"40-widevine-locations.patch":       false, // Fixes: arch linux works fine don't need to try alternative location for widevine
-			"41-widevine-buildflag.patch":       true,  // Fixes: enable widevine support
-			"42-connection-message.patch":       false, // Fixes: hardly seems important to 'update suggest updating your proxy when network is unreachable'
-			"43-unrar.patch":                    true,  // Disable: disable support for browsing rar files
-			"44-signin.patch":                   false, // Disable: already covered in the ungoogled patches
-			"45-android.patch":                  true,  // Disable: disable dependency on chrome/android
-			"46-fuzzers.patch":                  true,  // Disable: fuzzers as they aren't built anyway and only used for testing
-			"47-tracing.patch":                  true,  // Disable: disable tracing which depends on too many sourceless javascript files
-			"48-openh264.patch":                 false, // Disable: disable support for openh264
-			"49-chromeos.patch":                 true,  // Disable: ??
-			"50-perfetto.patch":                 true,  // Disable: disable dependencies on third_party perfetto
-			"51-installer.patch":                true,  // Disable: avoid building the chromium installer
-			"52-font-tests.patch":               true,  // Disable: disable building font tests
-			"53-swiftshader.patch":              true,  // Disable: avoid building the swiftshader library
-			"54-welcome-page.patch":             true,  // Disable: do not override the welcome page setting in preferences
-			"55-google-api-warning.patch":       true,  // Disable: disable Google's API key warning when they are removed from the PKGBUILD
-			"56-third-party-cookies.patch":      false, // Disable: covered by the inox patch 0006-modify-default-prefs.patch
-			"57-device-notifications.patch":     true,  // Disable: disable device discovery notifications in preferences
-			"58-int32.patch":                    true,  // Warning: fit int32_t enum values into 32 bits
-			"59-friend.patch":                   true,  // Warning: unfriend classses that friend themselves
-			"60-printf.patch":                   true,  // Warning: cast enums to int for use as printf arguments
-			"61-attribute.patch":                true,  // Warning: fix gcc optimization but attribute doesn't match warnings
-			"62-multichar.patch":                true,  // Warning: crashpad relies on multicharacter integer assignments
-			"63-deprecated.patch":               true,  // Warning: ignore deprecated bison directive warnings
-			"64-bool-compare.patch":             true,  // Warning: fix gcc bool-compare warnings
-			"65-enum-compare.patch":             true,  // Warning: fix gcc warnings about enum comparisions
-			"66-sign-compare.patch":             true,  // Warning: fix gcc sign-compare warnings
-			"67-initialization.patch":           true,  // Warning: source could be uninitialized
-			"68-unused-typedefs.patch":          true,  // Warning: fix type in unused local typedefs
-			"69-unused-functions.patch":         true,  // Warning: remove functions that are unused
-			"70-null-destination.patch":         true,  // Warning: use stack_buf before possible branching
-			"71-int-in-bool-context.patch":      true,  // Warning: fix int in bool context gcc warnings
+		"debian": {
+			{Name: "manpage.patch", Enabled: true, Reason: "Adds simple doc with link to documentation website", Tags: []string{"debian-branding"}},
+			{Name: "sandbox.patch", Enabled: false, Reason: "Debian specific error message to install chromium-sandbox", Tags: []string{"debian-branding"}},
+			{Name: "master-preferences.patch", Enabled: true, Reason: "Look for master preferences in /etc/chromium/master_preferences", Tags: []string{"debian-branding"}},
+			{Name: "libcxx.patch", Enabled: true, Reason: "Avoid chromium's embedded C++ library when bootstrapping", Tags: []string{"build-fix"}},
+			{Name: "parallel.patch", Enabled: true, Reason: "Respect specified number of parllel jobs when bootstrapping", Tags: []string{"build-fix"}},
+			{Name: "gcc_skcms_ice.patch", Enabled: true, Reason: "GCC ICE with optimized version", Tags: []string{"build-fix"}},
+			{Name: "pffffft-buildfix.patch", Enabled: true, Reason: "??", Tags: []string{"build-fix"}},
+			{Name: "skia-aarch64-buildfix.patch", Enabled: true, Reason: "??", Tags: []string{"build-fix"}},
+			{Name: "wrong-namespace.patch", Enabled: false, Reason: "gcc: not using as getting inspector protocol errors", Tags: []string{"build-fix"}},
+			{Name: "virtual-destructor.patch", Enabled: true, Reason: "gcc: a virtual destructor is called without this patch", Tags: []string{"build-fix"}},
+			{Name: "explicit-specialization.patch", Enabled: true, Reason: "gcc: fix for gcc explicit specialiazation namespace issue", Tags: []string{"build-fix"}},
+			{Name: "macro.patch", Enabled: false, Reason: "gcc6: can be ignored as arch linux is using gcc 9", Tags: []string{"build-fix"}},
+			{Name: "sizet.patch", Enabled: false, Reason: "gcc6: can be ignored as arch linux is using gcc 9", Tags: []string{"build-fix"}},
+			{Name: "atomic.patch", Enabled: false, Reason: "gcc6: can be ignored as arch linux is using gcc 9", Tags: []string{"build-fix"}},
+			{Name: "constexpr.patch", Enabled: false, Reason: "gcc6: can be ignored as arch linux is using gcc 9", Tags: []string{"build-fix"}},
+			{Name: "wtf-hashmap.patch", Enabled: false, Reason: "gcc6: can be ignored as arch linux is using gcc 9", Tags: []string{"build-fix"}},
+			{Name: "lambda-this.patch", Enabled: false, Reason: "gcc6: can be ignored as arch linux is using gcc 9", Tags: []string{"build-fix"}},
+			{Name: "map-insertion.patch", Enabled: false, Reason: "gcc6: can be ignored as arch linux is using gcc 9", Tags: []string{"build-fix"}},
+			{Name: "not-constexpr.patch", Enabled: false, Reason: "gcc6: can be ignored as arch linux is using gcc 9", Tags: []string{"build-fix"}},
+			{Name: "move-required.patch", Enabled: false, Reason: "gcc6: can be ignored as arch linux is using gcc 9", Tags: []string{"build-fix"}},
+			{Name: "use-after-move.patch", Enabled: false, Reason: "gcc6: can be ignored as arch linux is using gcc 9", Tags: []string{"build-fix"}},
+			{Name: "ambiguous-overloads.patch", Enabled: false, Reason: "gcc6: can be ignored as arch linux is using gcc 9", Tags: []string{"build-fix"}},
+			{Name: "ambiguous-initializer.patch", Enabled: false, Reason: "gcc6: can be ignored as arch linux is using gcc 9", Tags: []string{"build-fix"}},
+			{Name: "nullptr-copy-construct.patch", Enabled: false, Reason: "gcc6: can be ignored as arch linux is using gcc 9", Tags: []string{"build-fix"}},
+			{Name: "noexcept-redeclaration.patch", Enabled: false, Reason: "gcc6: can be ignored as arch linux is using gcc 9", Tags: []string{"build-fix"}},
+			{Name: "trivially-constructible.patch", Enabled: false, Reason: "gcc6: can be ignored as arch linux is using gcc 9", Tags: []string{"build-fix"}},
+			{Name: "designated-initializers.patch", Enabled: false, Reason: "gcc6: can be ignored as arch linux is using gcc 9", Tags: []string{"build-fix"}},
+			{Name: "specialization-namespace.patch", Enabled: false, Reason: "gcc6: can be ignored as arch linux is using gcc 9", Tags: []string{"build-fix"}},
+			{Name: "mojo.patch", Enabled: true, Reason: "Fixes: fix mojo layout test build error", Tags: []string{"build-fix"}},
+			{Name: "public.patch", Enabled: true, Reason: "Fixes: method needs to be public", Tags: []string{"build-fix"}},
+			{Name: "ps-print.patch", Enabled: true, Reason: "Fixes: add postscript(ps) printing capabiliy", Tags: []string{"build-fix"}},
+			{Name: "as-needed.patch", Enabled: true, Reason: "Fixes: some libraries fail to link when '--as-needed' is set", Tags: []string{"build-fix"}},
+			{Name: "inspector.patch", Enabled: false, Reason: "Fixes: not using as getting inspector protocol errors", Tags: []string{"build-fix"}},
+			{Name: "gpu-timeout.patch", Enabled: true, Reason: "Fixes: increase GPU timeout from 10sec to 20sec", Tags: []string{"build-fix"}},
+			{Name: "empty-array.patch", Enabled: true, Reason: "Fixes: arraysize macro fails for zero length array and add one char", Tags: []string{"build-fix"}},
+			{Name: "safebrowsing.patch", Enabled: false, Reason: "Fixes: not needed as were building with clang", Tags: []string{"build-fix"}},
+			{Name: "sequence-point.patch", Enabled: true, Reason: "Fixes: fix undefined order in which expressions are evaluated", Tags: []string{"build-fix"}},
+			{Name: "jumbo-namespace.patch", Enabled: true, Reason: "Fixes: jumbo build has trouble with these namespaces", Tags: []string{"build-fix"}},
+			{Name: "template-export.patch", Enabled: true, Reason: "Fixes: implementation of template function must be in header to be exported", Tags: []string{"build-fix"}},
+			{Name: "widevine-revision.patch", Enabled: true, Reason: "Fixes: set widevine version as undefined", Tags: []string{"build-fix"}},
+			{Name: "widevine-locations.patch", Enabled: false, Reason: "Fixes: arch linux works fine don't need to try alternative location for widevine", Tags: []string{"build-fix"}},
+			{Name: "widevine-buildflag.patch", Enabled: true, Reason: "Fixes: enable widevine support", Tags: []string{"build-fix"}},
+			{Name: "connection-message.patch", Enabled: false, Reason: "Fixes: hardly seems important to 'update suggest updating your proxy when network is unreachable'", Tags: []string{"build-fix"}},
+			{Name: "unrar.patch", Enabled: true, Reason: "Disable: disable support for browsing rar files"},
+			{Name: "signin.patch", Enabled: false, Reason: "Disable: already covered in the ungoogled patches", Tags: []string{"privacy"}},
+			{Name: "android.patch", Enabled: true, Reason: "Disable: disable dependency on chrome/android"},
+			{Name: "fuzzers.patch", Enabled: true, Reason: "Disable: fuzzers as they aren't built anyway and only used for testing"},
+			{Name: "tracing.patch", Enabled: true, Reason: "Disable: disable tracing which depends on too many sourceless javascript files"},
+			{Name: "openh264.patch", Enabled: false, Reason: "Disable: disable support for openh264"},
+			{Name: "chromeos.patch", Enabled: true, Reason: "Disable: ??"},
+			{Name: "perfetto.patch", Enabled: true, Reason: "Disable: disable dependencies on third_party perfetto"},
+			{Name: "installer.patch", Enabled: true, Reason: "Disable: avoid building the chromium installer"},
+			{Name: "font-tests.patch", Enabled: true, Reason: "Disable: disable building font tests"},
+			{Name: "swiftshader.patch", Enabled: true, Reason: "Disable: avoid building the swiftshader library"},
+			{Name: "welcome-page.patch", Enabled: true, Reason: "Disable: do not override the welcome page setting in preferences", Tags: []string{"ui"}},
+			{Name: "google-api-warning.patch", Enabled: true, Reason: "Disable: disable Google's API key warning when they are removed from the PKGBUILD", Tags: []string{"ui"}},
+			{Name: "third-party-cookies.patch", Enabled: false, Reason: "Disable: covered by the inox patch 0006-modify-default-prefs.patch", Tags: []string{"privacy"}},
+			{Name: "device-notifications.patch", Enabled: true, Reason: "Disable: disable device discovery notifications in preferences", Tags: []string{"privacy"}},
+			{Name: "int32.patch", Enabled: true, Reason: "Warning: fit int32_t enum values into 32 bits", Tags: []string{"build-fix"}},
+			{Name: "friend.patch", Enabled: true, Reason: "Warning: unfriend classses that friend themselves", Tags: []string{"build-fix"}},
+			{Name: "printf.patch", Enabled: true, Reason: "Warning: cast enums to int for use as printf arguments", Tags: []string{"build-fix"}},
+			{Name: "attribute.patch", Enabled: true, Reason: "Warning: fix gcc optimization but attribute doesn't match warnings", Tags: []string{"build-fix"}},
+			{Name: "multichar.patch", Enabled: true, Reason: "Warning: crashpad relies on multicharacter integer assignments", Tags: []string{"build-fix"}},
+			{Name: "deprecated.patch", Enabled: true, Reason: "Warning: ignore deprecated bison directive warnings", Tags: []string{"build-fix"}},
+			{Name: "bool-compare.patch", Enabled: true, Reason: "Warning: fix gcc bool-compare warnings", Tags: []string{"build-fix"}},
+			{Name: "enum-compare.patch", Enabled: true, Reason: "Warning: fix gcc warnings about enum comparisions", Tags: []string{"build-fix"}},
+			{Name: "sign-compare.patch", Enabled: true, Reason: "Warning: fix gcc sign-compare warnings", Tags: []string{"build-fix"}},
+			{Name: "initialization.patch", Enabled: true, Reason: "Warning: source could be uninitialized", Tags: []string{"build-fix"}},
+			{Name: "unused-typedefs.patch", Enabled: true, Reason: "Warning: fix type in unused local typedefs", Tags: []string{"build-fix"}},
+			{Name: "unused-functions.patch", Enabled: true, Reason: "Warning: remove functions that are unused", Tags: []string{"build-fix"}},
+			{Name: "null-destination.patch", Enabled: true, Reason: "Warning: use stack_buf before possible branching", Tags: []string{"build-fix"}},
+			{Name: "int-in-bool-context.patch", Enabled: true, Reason: "Warning: fix int in bool context gcc warnings", Tags: []string{"build-fix"}},
 
 			// Disabling all the system libs as its a pain to continually rebuild chromium every time a lib gets updated
-			"72-vpx.patch":        false, // System: arch linux supports VP9 so we don't need to disable it in libvpx
-			"73-icu.patch":        false, // System: arch linux PKGBUILD has a system lib call out for this already
-			"74-gtk2.patch":       false, // System: arch linux packages work fine when building against GTK3
-			"75-jpeg.patch":       false, // System: arch linux PKGBUILD has a system lib call out for this already
-			"76-lcms.patch":       false, // System: use system lcms for pdfium
-			"77-nspr.patch":       false, // System: build using the system nspr library
-			"78-zlib.patch":       false, // System: arch PKGBUILD has a system lib call out for this already
-			"79-event.patch":      false, // System: might be causing libeevnt build failure - build using the system libevent library
-			"80-ffmpeg.patch":     false, // System: arch linux PKGBUILD has a system lib call out for this already
-			"81-jsoncpp.patch":    false, // System: use system jsoncpp
-			"82-openjpeg.patch":   false, // System: build system using openjpeg
-			"83-convertutf.patch": false, // System: use ICU for UTF8 conversions (eleminates ConvertUTF embedded code copy)
-			"84-icu63.patch":      false, // System: arch linux has newer icu don't need to maintain compt with 63
+			{Name: "vpx.patch", Enabled: false, Reason: "System: arch linux supports VP9 so we don't need to disable it in libvpx", Tags: []string{"system-libs"}},
+			{Name: "icu.patch", Enabled: false, Reason: "System: arch linux PKGBUILD has a system lib call out for this already", Tags: []string{"system-libs"}},
+			{Name: "gtk2.patch", Enabled: false, Reason: "System: arch linux packages work fine when building against GTK3", Tags: []string{"system-libs"}},
+			{Name: "jpeg.patch", Enabled: false, Reason: "System: arch linux PKGBUILD has a system lib call out for this already", Tags: []string{"system-libs"}},
+			{Name: "lcms.patch", Enabled: false, Reason: "System: use system lcms for pdfium", Tags: []string{"system-libs"}},
+			{Name: "nspr.patch", Enabled: false, Reason: "System: build using the system nspr library", Tags: []string{"system-libs"}},
+			{Name: "zlib.patch", Enabled: false, Reason: "System: arch PKGBUILD has a system lib call out for this already", Tags: []string{"system-libs"}},
+			{Name: "event.patch", Enabled: false, Reason: "System: might be causing libeevnt build failure - build using the system libevent library", Tags: []string{"system-libs"}},
+			{Name: "ffmpeg.patch", Enabled: false, Reason: "System: arch linux PKGBUILD has a system lib call out for this already", Tags: []string{"system-libs"}},
+			{Name: "jsoncpp.patch", Enabled: false, Reason: "System: use system jsoncpp", Tags: []string{"system-libs"}},
+			{Name: "openjpeg.patch", Enabled: false, Reason: "System: build system using openjpeg", Tags: []string{"system-libs"}},
+			{Name: "convertutf.patch", Enabled: false, Reason: "System: use ICU for UTF8 conversions (eleminates ConvertUTF embedded code copy)", Tags: []string{"system-libs"}},
+			{Name: "icu63.patch", Enabled: false, Reason: "System: arch linux has newer icu don't need to maintain compt with 63", Tags: []string{"system-libs"}},
 		},
 
 		// Credit to github.com/Eloston/ungoogled-chromium
-		gDistros.ungoogled: {
-			"00-chromium-exclude_unwind_tables.patch":                       true,  // inox: Exclude unwind dumps as stack dumps can be unwound by Crashpad at a later time
-			"01-0001-fix-building-without-safebrowsing.patch":               true,  // inox: Fix building with 'safe_browsing_mode=0' set
-			"02-0003-disable-autofill-download-manager.patch":               true,  // inox: Disables HTML AutoFill data transmission to Google
-			"03-0004-disable-google-url-tracker.patch":                      false, // inox: Disable Google tracking your entered urls, but breaks omnibar search
-			"04-0005-disable-default-extensions.patch":                      false, // inox: I want to keep the webstore
-			"05-0007-disable-web-resource-service.patch":                    true,  // inox: Disables downloading dynamic configuration from Google for chromium
-			"06-0009-disable-google-ipv6-probes.patch":                      true,  // inox: Change IPv6 DNS probes to Google over to k.root-servers.net
-			"07-0010-disable-gcm-status-check.patch":                        true,  // inox: Disable Google Cloud-Messaging status probes, GCM allows direct msg to device
-			"08-0014-disable-translation-lang-fetch.patch":                  true,  // inox: Disable language fetching from Google when settings are opened the first time
-			"09-0015-disable-update-pings.patch":                            true,  // inox: Disable update pings to Google
-			"10-0017-disable-new-avatar-menu.patch":                         true,  // inox: Disable Google Avatar signin menu
-			"11-0021-disable-rlz.patch":                                     true,  // inox: Disable RLZ
-			"12-unrar.patch":                                                false, // debian: already covered by debian
-			"13-perfetto.patch":                                             false, // debian: already covered by debian
-			"14-safe_browsing-disable-incident-reporting.patch":             true,  // iridium: disable safe browsing incident reporting
-			"15-safe_browsing-disable-reporting-of-safebrowsing-over.patch": true,  // iridium: disable safe browsing incident reporting
-			"16-all-add-trk-prefixes-to-possibly-evil-connections.patch":    false, // iridium: stops the webstore from working
-			"17-disable-crash-reporter.patch":                               true,  // ungoogled: disable crash reporting
-			"18-disable-google-host-detection.patch":                        false, // ungoogled: disable detecting Google hosts
-			"19-replace-google-search-engine-with-nosearch.patch":           false, // ungoogled: leaving in the google search engine
-			"20-disable-signin.patch":                                       true,  // ungoogled: disable browser signin
-			"21-disable-translate.patch":                                    true,  // ungoogled: disable browser translate
-			"22-disable-untraceable-urls.patch":                             false, // ungoogled: stops the webstore from working
-			"23-disable-profile-avatar-downloading.patch":                   true,  // ungoogled: disable downloading profile avatar
-			"24-disable-gcm.patch":                                          true,  // ungoogled: disable Google Cloud Messaging
-			"25-disable-domain-reliability.patch":                           true,  // ungoogled: disable domain reliability component
-			"26-block-trk-and-subdomains.patch":                             false, // ungoogled: stops the webstore from working
-			"27-fix-building-without-one-click-signin.patch":                true,  // ungoogled: fix building without one click signin
-			"28-disable-gaia.patch":                                         true,  // ungoogled: ensure can't be activated even without signing in
-			"29-disable-fonts-googleapis-references.patch":                  false, // ungoogled: google fonts are alright, leaving in
-			"30-disable-webstore-urls.patch":                                false, // ungoogled: still want access to the webstore so leaving this in
-			"31-fix-learn-doubleclick-hsts.patch":                           true,  // ungoogled:
-			"32-disable-webrtc-log-uploader.patch":                          true,  // ungoogled: disable webrtc log uploader
-			"33-use-local-devtools-files.patch":                             true,  // ungoogled: bundle in dev files rather than download them
-			"34-disable-network-time-tracker.patch":                         true,  // ungoogled: disable network time tracker
-			"35-disable-mei-preload.patch":                                  true,  // ungoogled: disable mei preload
-			"36-fix-building-without-safebrowsing.patch":                    true,  // ungoogled: fix building without safebrowsing
-			"37-disable-fetching-field-trials.patch":                        true,  // bromite: disable fetching field trials
-
-			"38-chromium-widevine.patch":                                    false, // ungoogled: already covered by debian
-			"39-0006-modify-default-prefs.patch":                            true,  // inox: set sane defaults for preferences
-			"40-0008-restore-classic-ntp.patch":                             true,  // inox: the new NTP (New Tag Page) pulls from Google including tracking identifier
-			"41-0011-add-duckduckgo-search-engine.patch":                    true,  // inox: set duckduckgo search option as default for countries with no default
-			"42-0013-disable-missing-key-warning.patch":                     true,  // inox: disable missing google api key warning
-			"43-0016-chromium-sandbox-pie.patch":                            true,  // inox: hardening the sandbox with Position Independent Code(PIE) against ROP exploits
-			"44-0018-disable-first-run-behaviour.patch":                     true,  // inox: disable first run behavior
-			"45-0019-disable-battery-status-service.patch":                  true,  // inox: disable battery status service
-			"46-parallel.patch":                                             false, // debian: already covered
-			"47-ps-print.patch":                                             false, // debian: already covered
-			"48-inspector.patch":                                            false, // debian: already covered
-			"49-connection-message.patch":                                   false, // debian: already covered
-			"50-android.patch":                                              false, // debian: already covered
-			"51-fuzzers.patch":                                              false, // debian: already covered
-			"52-welcome-page.patch":                                         false, // debian: already covered
-			"53-google-api-warning.patch":                                   false, // debian: already covered
-			"54-device-notifications.patch":                                 false, // debian: already covered
-			"55-initialization.patch":                                       false, // debian: already covered
-			"56-net-cert-increase-default-key-length-for-newly-gener.patch": true,  // iridium: increase default key length from 1024 => 2056
-			"57-mime_util-force-text-x-suse-ymp-to-be-downloaded.patch":     false, // iridium: force download of ymp files
-			"58-prefs-only-keep-cookies-until-exit.patch":                   true,  // iridium: set cookies to only be kept unit exit
-			"59-prefs-always-prompt-for-download-directory-by-defaul.patch": true,  // iridium: always prompt for download directory by default
-			"60-updater-disable-auto-update.patch":                          false, // iridium: auto update is already turned off for Linux
-			"61-Remove-EV-certificates.patch":                               false, // iridium: just cosmetics - skipping
-			"62-browser-disable-profile-auto-import-on-first-run.patch":     true,  // iridium: disable auto importing stuff on first run
-			"63-add-third-party-ungoogled.patch":                            false, // ungoogled: skipping
-			"64-disable-formatting-in-omnibox.patch":                        false, // ungoogled: skipping
-			"65-popups-to-tabs.patch":                                       true,  // ungoogled: force pop up windows to end up as a new tab
-			"66-add-ipv6-probing-option.patch":                              true,  // ungoogled: disable IPV6 probing
-			"67-remove-disable-setuid-sandbox-as-bad-flag.patch":            false, // ungoogled: skipping
-			"68-disable-intranet-redirect-detector.patch":                   true,  // ungoogled: disable internet redirect detector, stop extraneous dns requests
-			"69-enable-page-saving-on-more-pages.patch":                     true,  // ungoogled: allow saving of more documents rather than just HTTP/HTTPS
-			"70-disable-download-quarantine.patch":                          true,  // ungoogled: disable file download quarantine, always available
-			"71-fix-building-without-mdns-and-service-discovery.patch":      true,  // ungoogled: fix building without mdns and service discovery
-			"72-add-flag-to-stack-tabs.patch":                               false, // ungoogled: skipping
-			"73-add-flag-to-configure-extension-downloading.patch":          false, // ungoogled: skipping
-			"74-add-flag-for-search-engine-collection.patch":                false, // ungoogled: skipping
-			"75-add-flag-to-disable-beforeunload.patch":                     false, // ungoogled: skipping
-			"76-add-flag-to-force-punycode-hostnames.patch":                 false, // ungoogled: skipping
-			"77-searx.patch":                                                false, // ungoogled: searx seems to crash and not work
-			"78-disable-webgl-renderer-info.patch":                          true,  // ungoogled: removing webgl data leakage
-			"79-add-flag-to-show-avatar-button.patch":                       false, // ungoogled: skipping
-			"80-add-suggestions-url-field.patch":                            false, // ungoogled: skipping
-			"81-add-flag-to-hide-crashed-bubble.patch":                      false, // ungoogled: skipping
-			"82-default-to-https-scheme.patch":                              true,  // ungoogled: default urls without a schema to https
-			"83-add-flag-to-scroll-tabs.patch":                              false, // ungoogled: skipping
-			"84-enable-paste-and-go-new-tab-button.patch":                   true,  // ungoogled: enable paste and go new tab
-			"85-fingerprinting-flags-client-rects-and-measuretext.patch":    false, // bromite: skipping
-			"86-flag-max-connections-per-host.patch":                        false, // bromite: skipping
-			"87-flag-fingerprinting-canvas-image-data-noise.patch":          false, // bromite: skipping
+		"ungoogled": {
+			{Name: "chromium-exclude_unwind_tables.patch", Enabled: true, Source: "inox", Reason: "Exclude unwind dumps as stack dumps can be unwound by Crashpad at a later time"},
+			{Name: "0001-fix-building-without-safebrowsing.patch", Enabled: true, Source: "inox", Reason: "Fix building with 'safe_browsing_mode=0' set", Tags: []string{"build-fix"}},
+			{Name: "0003-disable-autofill-download-manager.patch", Enabled: true, Source: "inox", Reason: "Disables HTML AutoFill data transmission to Google", Tags: []string{"privacy"}},
+			{Name: "0004-disable-google-url-tracker.patch", Enabled: false, Source: "inox", Reason: "Disable Google tracking your entered urls, but breaks omnibar search", Tags: []string{"privacy"}},
+			{Name: "0005-disable-default-extensions.patch", Enabled: false, Source: "inox", Reason: "I want to keep the webstore", Tags: []string{"privacy"}},
+			{Name: "0007-disable-web-resource-service.patch", Enabled: true, Source: "inox", Reason: "Disables downloading dynamic configuration from Google for chromium", Tags: []string{"privacy"}},
+			{Name: "0009-disable-google-ipv6-probes.patch", Enabled: true, Source: "inox", Reason: "Change IPv6 DNS probes to Google over to k.root-servers.net", Tags: []string{"privacy"}},
+			{Name: "0010-disable-gcm-status-check.patch", Enabled: true, Source: "inox", Reason: "Disable Google Cloud-Messaging status probes, GCM allows direct msg to device", Tags: []string{"privacy"}},
+			{Name: "0014-disable-translation-lang-fetch.patch", Enabled: true, Source: "inox", Reason: "Disable language fetching from Google when settings are opened the first time", Tags: []string{"privacy"}},
+			{Name: "0015-disable-update-pings.patch", Enabled: true, Source: "inox", Reason: "Disable update pings to Google", Tags: []string{"privacy"}},
+			{Name: "0017-disable-new-avatar-menu.patch", Enabled: true, Source: "inox", Reason: "Disable Google Avatar signin menu", Tags: []string{"ui"}},
+			{Name: "0021-disable-rlz.patch", Enabled: true, Source: "inox", Reason: "Disable RLZ", Tags: []string{"privacy"}},
+			{Name: "unrar.patch", Enabled: false, Source: "debian", Reason: "already covered by debian"},
+			{Name: "perfetto.patch", Enabled: false, Source: "debian", Reason: "already covered by debian"},
+			{Name: "safe_browsing-disable-incident-reporting.patch", Enabled: true, Source: "iridium", Reason: "disable safe browsing incident reporting", Tags: []string{"privacy"}},
+			{Name: "safe_browsing-disable-reporting-of-safebrowsing-over.patch", Enabled: true, Source: "iridium", Reason: "disable safe browsing incident reporting", Tags: []string{"privacy"}},
+			{Name: "all-add-trk-prefixes-to-possibly-evil-connections.patch", Enabled: false, Source: "iridium", Reason: "stops the webstore from working", Tags: []string{"privacy"}},
+			{Name: "disable-crash-reporter.patch", Enabled: true, Source: "ungoogled", Reason: "disable crash reporting", Tags: []string{"privacy"}},
+			{Name: "disable-google-host-detection.patch", Enabled: false, Source: "ungoogled", Reason: "disable detecting Google hosts", Tags: []string{"privacy"}},
+			{Name: "replace-google-search-engine-with-nosearch.patch", Enabled: false, Source: "ungoogled", Reason: "leaving in the google search engine", Tags: []string{"privacy"}},
+			{Name: "disable-signin.patch", Enabled: true, Source: "ungoogled", Reason: "disable browser signin", Tags: []string{"privacy"}},
+			{Name: "disable-translate.patch", Enabled: true, Source: "ungoogled", Reason: "disable browser translate", Tags: []string{"privacy"}},
+			{Name: "disable-untraceable-urls.patch", Enabled: false, Source: "ungoogled", Reason: "stops the webstore from working", Tags: []string{"privacy"}},
+			{Name: "disable-profile-avatar-downloading.patch", Enabled: true, Source: "ungoogled", Reason: "disable downloading profile avatar", Tags: []string{"privacy"}},
+			{Name: "disable-gcm.patch", Enabled: true, Source: "ungoogled", Reason: "disable Google Cloud Messaging", Tags: []string{"privacy"}},
+			{Name: "disable-domain-reliability.patch", Enabled: true, Source: "ungoogled", Reason: "disable domain reliability component", Tags: []string{"privacy"}},
+			{Name: "block-trk-and-subdomains.patch", Enabled: false, Source: "ungoogled", Reason: "stops the webstore from working", Tags: []string{"privacy"}},
+			{Name: "fix-building-without-one-click-signin.patch", Enabled: true, Source: "ungoogled", Reason: "fix building without one click signin", Tags: []string{"build-fix"}},
+			{Name: "disable-gaia.patch", Enabled: true, Source: "ungoogled", Reason: "ensure can't be activated even without signing in", Tags: []string{"privacy"}},
+			{Name: "disable-fonts-googleapis-references.patch", Enabled: false, Source: "ungoogled", Reason: "google fonts are alright, leaving in", Tags: []string{"privacy"}},
+			{Name: "disable-webstore-urls.patch", Enabled: false, Source: "ungoogled", Reason: "still want access to the webstore so leaving this in", Tags: []string{"privacy"}},
+			{Name: "fix-learn-doubleclick-hsts.patch", Enabled: true, Source: "ungoogled", Tags: []string{"build-fix"}},
+			{Name: "disable-webrtc-log-uploader.patch", Enabled: true, Source: "ungoogled", Reason: "disable webrtc log uploader", Tags: []string{"privacy"}},
+			{Name: "use-local-devtools-files.patch", Enabled: true, Source: "ungoogled", Reason: "bundle in dev files rather than download them"},
+			{Name: "disable-network-time-tracker.patch", Enabled: true, Source: "ungoogled", Reason: "disable network time tracker", Tags: []string{"privacy"}},
+			{Name: "disable-mei-preload.patch", Enabled: true, Source: "ungoogled", Reason: "disable mei preload", Tags: []string{"privacy"}},
+			{Name: "fix-building-without-safebrowsing.patch", Enabled: true, Source: "ungoogled", Reason: "fix building without safebrowsing", Tags: []string{"build-fix"}},
+			{Name: "disable-fetching-field-trials.patch", Enabled: true, Source: "bromite", Reason: "disable fetching field trials", Tags: []string{"privacy"}},
+
+			{Name: "chromium-widevine.patch", Enabled: false, Source: "ungoogled", Reason: "already covered by debian"},
+			{Name: "0006-modify-default-prefs.patch", Enabled: true, Source: "inox", Reason: "set sane defaults for preferences"},
+			{Name: "0008-restore-classic-ntp.patch", Enabled: true, Source: "inox", Reason: "the new NTP (New Tag Page) pulls from Google including tracking identifier", Tags: []string{"ui"}},
+			{Name: "0011-add-duckduckgo-search-engine.patch", Enabled: true, Source: "inox", Reason: "set duckduckgo search option as default for countries with no default", Tags: []string{"privacy"}},
+			{Name: "0013-disable-missing-key-warning.patch", Enabled: true, Source: "inox", Reason: "disable missing google api key warning", Tags: []string{"ui"}},
+			{Name: "0016-chromium-sandbox-pie.patch", Enabled: true, Source: "inox", Reason: "hardening the sandbox with Position Independent Code(PIE) against ROP exploits"},
+			{Name: "0018-disable-first-run-behaviour.patch", Enabled: true, Source: "inox", Reason: "disable first run behavior", Tags: []string{"privacy"}},
+			{Name: "0019-disable-battery-status-service.patch", Enabled: true, Source: "inox", Reason: "disable battery status service", Tags: []string{"privacy"}},
+			{Name: "parallel.patch", Enabled: false, Source: "debian", Reason: "already covered"},
+			{Name: "ps-print.patch", Enabled: false, Source: "debian", Reason: "already covered"},
+			{Name: "inspector.patch", Enabled: false, Source: "debian", Reason: "already covered"},
+			{Name: "connection-message.patch", Enabled: false, Source: "debian", Reason: "already covered"},
+			{Name: "android.patch", Enabled: false, Source: "debian", Reason: "already covered"},
+			{Name: "fuzzers.patch", Enabled: false, Source: "debian", Reason: "already covered"},
+			{Name: "welcome-page.patch", Enabled: false, Source: "debian", Reason: "already covered"},
+			{Name: "google-api-warning.patch", Enabled: false, Source: "debian", Reason: "already covered"},
+			{Name: "device-notifications.patch", Enabled: false, Source: "debian", Reason: "already covered"},
+			{Name: "initialization.patch", Enabled: false, Source: "debian", Reason: "already covered"},
+			{Name: "net-cert-increase-default-key-length-for-newly-gener.patch", Enabled: true, Source: "iridium", Reason: "increase default key length from 1024 => 2056"},
+			{Name: "mime_util-force-text-x-suse-ymp-to-be-downloaded.patch", Enabled: false, Source: "iridium", Reason: "force download of ymp files"},
+			{Name: "prefs-only-keep-cookies-until-exit.patch", Enabled: true, Source: "iridium", Reason: "set cookies to only be kept unit exit", Tags: []string{"privacy"}},
+			{Name: "prefs-always-prompt-for-download-directory-by-defaul.patch", Enabled: true, Source: "iridium", Reason: "always prompt for download directory by default", Tags: []string{"ui"}},
+			{Name: "updater-disable-auto-update.patch", Enabled: false, Source: "iridium", Reason: "auto update is already turned off for Linux", Tags: []string{"privacy"}},
+			{Name: "Remove-EV-certificates.patch", Enabled: false, Source: "iridium", Reason: "just cosmetics - skipping"},
+			{Name: "browser-disable-profile-auto-import-on-first-run.patch", Enabled: true, Source: "iridium", Reason: "disable auto importing stuff on first run", Tags: []string{"privacy"}},
+			{Name: "add-third-party-ungoogled.patch", Enabled: false, Source: "ungoogled", Reason: "skipping"},
+			{Name: "disable-formatting-in-omnibox.patch", Enabled: false, Source: "ungoogled", Reason: "skipping", Tags: []string{"ui"}},
+			{Name: "popups-to-tabs.patch", Enabled: true, Source: "ungoogled", Reason: "force pop up windows to end up as a new tab", Tags: []string{"ui"}},
+			{Name: "add-ipv6-probing-option.patch", Enabled: true, Source: "ungoogled", Reason: "disable IPV6 probing"},
+			{Name: "remove-disable-setuid-sandbox-as-bad-flag.patch", Enabled: false, Source: "ungoogled", Reason: "skipping", Tags: []string{"privacy"}},
+			{Name: "disable-intranet-redirect-detector.patch", Enabled: true, Source: "ungoogled", Reason: "disable internet redirect detector, stop extraneous dns requests", Tags: []string{"privacy"}},
+			{Name: "enable-page-saving-on-more-pages.patch", Enabled: true, Source: "ungoogled", Reason: "allow saving of more documents rather than just HTTP/HTTPS", Tags: []string{"ui"}},
+			{Name: "disable-download-quarantine.patch", Enabled: true, Source: "ungoogled", Reason: "disable file download quarantine, always available", Tags: []string{"privacy"}},
+			{Name: "fix-building-without-mdns-and-service-discovery.patch", Enabled: true, Source: "ungoogled", Reason: "fix building without mdns and service discovery", Tags: []string{"build-fix"}},
+			{Name: "add-flag-to-stack-tabs.patch", Enabled: false, Source: "ungoogled", Reason: "skipping", Tags: []string{"ui"}},
+			{Name: "add-flag-to-configure-extension-downloading.patch", Enabled: false, Source: "ungoogled", Reason: "skipping", Tags: []string{"ui"}},
+			{Name: "add-flag-for-search-engine-collection.patch", Enabled: false, Source: "ungoogled", Reason: "skipping", Tags: []string{"ui"}},
+			{Name: "add-flag-to-disable-beforeunload.patch", Enabled: false, Source: "ungoogled", Reason: "skipping", Tags: []string{"ui"}},
+			{Name: "add-flag-to-force-punycode-hostnames.patch", Enabled: false, Source: "ungoogled", Reason: "skipping", Tags: []string{"ui"}},
+			{Name: "searx.patch", Enabled: false, Source: "ungoogled", Reason: "searx seems to crash and not work", Tags: []string{"privacy"}},
+			{Name: "disable-webgl-renderer-info.patch", Enabled: true, Source: "ungoogled", Reason: "removing webgl data leakage", Tags: []string{"privacy"}},
+			{Name: "add-flag-to-show-avatar-button.patch", Enabled: false, Source: "ungoogled", Reason: "skipping", Tags: []string{"ui"}},
+			{Name: "add-suggestions-url-field.patch", Enabled: false, Source: "ungoogled", Reason: "skipping", Tags: []string{"ui"}},
+			{Name: "add-flag-to-hide-crashed-bubble.patch", Enabled: false, Source: "ungoogled", Reason: "skipping", Tags: []string{"ui"}},
+			{Name: "default-to-https-scheme.patch", Enabled: true, Source: "ungoogled", Reason: "default urls without a schema to https", Tags: []string{"ui"}},
+			{Name: "add-flag-to-scroll-tabs.patch", Enabled: false, Source: "ungoogled", Reason: "skipping", Tags: []string{"ui"}},
+			{Name: "enable-paste-and-go-new-tab-button.patch", Enabled: true, Source: "ungoogled", Reason: "enable paste and go new tab", Tags: []string{"ui"}},
+			{Name: "fingerprinting-flags-client-rects-and-measuretext.patch", Enabled: false, Source: "bromite", Reason: "skipping", Tags: []string{"privacy"}},
+			{Name: "flag-max-connections-per-host.patch", Enabled: false, Source: "bromite", Reason: "skipping"},
+			{Name: "flag-fingerprinting-canvas-image-data-noise.patch", Enabled: false, Source: "bromite", Reason: "skipping", Tags: []string{"privacy"}},
 		},
+
+		// Credit to github.com/gcarq/inox-patchset
+		// The ungoogled patch set already carries the inox patches we want so all are disabled here
+		"inox": {
+			{Name: "0001-fix-building-without-safebrowsing.patch", Enabled: false, Reason: "already covered by the ungoogled patch set"},
+			{Name: "0003-disable-autofill-download-manager.patch", Enabled: false, Reason: "already covered by the ungoogled patch set"},
+			{Name: "0004-disable-google-url-tracker.patch", Enabled: false, Reason: "breaks omnibar search"},
+			{Name: "0005-disable-default-extensions.patch", Enabled: false, Reason: "I want to keep the webstore"},
+			{Name: "0006-modify-default-prefs.patch", Enabled: false, Reason: "already covered by the ungoogled patch set"},
+			{Name: "0007-disable-web-resource-service.patch", Enabled: false, Reason: "already covered by the ungoogled patch set"},
+			{Name: "0008-restore-classic-ntp.patch", Enabled: false, Reason: "already covered by the ungoogled patch set"},
+			{Name: "0009-disable-google-ipv6-probes.patch", Enabled: false, Reason: "already covered by the ungoogled patch set"},
+			{Name: "0010-disable-gcm-status-check.patch", Enabled: false, Reason: "already covered by the ungoogled patch set"},
+			{Name: "0011-add-duckduckgo-search-engine.patch", Enabled: false, Reason: "already covered by the ungoogled patch set"},
+			{Name: "0012-branding.patch", Enabled: false, Reason: "just cosmetics - skipping"},
+			{Name: "0013-disable-missing-key-warning.patch", Enabled: false, Reason: "already covered by the ungoogled patch set"},
+			{Name: "0014-disable-translation-lang-fetch.patch", Enabled: false, Reason: "already covered by the ungoogled patch set"},
+			{Name: "0015-disable-update-pings.patch", Enabled: false, Reason: "already covered by the ungoogled patch set"},
+			{Name: "0016-chromium-sandbox-pie.patch", Enabled: false, Reason: "already covered by the ungoogled patch set"},
+			{Name: "0017-disable-new-avatar-menu.patch", Enabled: false, Reason: "already covered by the ungoogled patch set"},
+			{Name: "0018-disable-first-run-behaviour.patch", Enabled: false, Reason: "already covered by the ungoogled patch set"},
+			{Name: "0019-disable-battery-status-service.patch", Enabled: false, Reason: "already covered by the ungoogled patch set"},
+			{Name: "0021-disable-rlz.patch", Enabled: false, Reason: "already covered by the ungoogled patch set"},
+			{Name: "9000-disable-metrics.patch", Enabled: false, Reason: "already covered by the ungoogled patch set"},
+		},
+
+		// Credit to git.iridiumbrowser.de
+		// The ungoogled patch set already carries the iridium patches we want so all are left disabled
+		"iridium": {},
 	}
 )
 
@@ -246,6 +279,20 @@ type Chroma struct {
 	patchesDir    string // path to the patches dir in the chromium package
 	extensionsDir string // path to the src/exentions dir in the chromium package
 	chromiumVer   string // target version of chrome pulled from the PKGBUILD
+	manifestFile  string // path to the manifest to use instead of the default location
+
+	// Working manifest built from the defaults and the manifest file
+	manifest *Manifest
+
+	// Actions performed or planned in order and the planned state of the disk when making no changes
+	plan    []*Action
+	planned map[string]bool   // paths planned to exist or not
+	removed []string          // directories planned to be removed
+	sources map[string]string // patch source types used rather than the manifest's by patch set
+
+	// Journal recording the performed actions of the command being run
+	journal *journal
+	command string
 }
 
 // New initializes the CLI with the given options
@@ -276,14 +323,30 @@ Examples:
   # Download latest patches for debian
   chroma down patch debian
 
-  # Enable/disable patches according to the internal mapping
+  # Enable/disable patches according to the manifest
   chroma sort debian
+
+  # Review the changes chroma would make then apply them
+  chroma plan -o plan.json
+  chroma apply plan.json
 `,
 			boilerPlate),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return cmd.Help()
 		},
 
+		// Remember the command being run for the journal
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			chroma.command = strings.Join(append([]string{cmd.CommandPath()}, args...), " ")
+		},
+
+		// Print out the ordered plan when making no changes
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			if chroma.dryrun {
+				chroma.printPlan()
+			}
+		},
+
 		// Turns off RunE triggering help on errors, help is still
 		// printed out for normal case, like missing params or -h
 		SilenceUsage: true,
@@ -293,8 +356,20 @@ Examples:
 	}
 
 	chroma.cmd.AddCommand(
+		chroma.newApplyCmd(),
+		chroma.newConflictsCmd(),
+		chroma.newDisableCmd(),
 		chroma.newDownloadCmd(),
+		chroma.newEnableCmd(),
+		chroma.newExplainCmd(),
+		chroma.newListCmd(),
+		chroma.newPatchesCmd(),
+		chroma.newPkgbuildCmd(),
+		chroma.newPlanCmd(),
+		chroma.newSeriesCmd(),
 		chroma.newSortCmd(),
+		chroma.newTouchesCmd(),
+		chroma.newUndoCmd(),
 		chroma.newVersionCmd(),
 	)
 
@@ -310,6 +385,9 @@ Examples:
 	// --pkgbuild
 	chroma.cmd.PersistentFlags().StringVar(&chroma.pkgbuild, "pkgbuild", "", "Use this specific PKGBUILD to derive pathes from")
 
+	// --manifest
+	chroma.cmd.PersistentFlags().StringVar(&chroma.manifestFile, "manifest", "", "Use this specific manifest rather than the one next to the PKGBUILD")
+
 	// Setup logging after we've read in the env variables
 	chroma.setupLogging()
 
@@ -355,11 +433,31 @@ func (chroma *Chroma) configure() (err error) {
 		err = errors.Errorf("failed to extract the chromium version from the VERSION file")
 		return
 	}
+	chroma.chromiumVer = strings.Trim(strings.TrimSpace(chroma.chromiumVer), `'"`)
+
+	// Load the manifest overriding the built-in defaults
+	// ---------------------------------------------------------------------------------------------
+	if err = chroma.loadManifests(); err != nil {
+		return
+	}
+
+	// Version constrained decisions can't be checked without a valid chromium version
+	if version, e := parseChromiumVersion(chroma.chromiumVer); e == nil {
+		chroma.manifest.setVersion(version)
+	} else if chroma.manifest.versioned() {
+		err = errors.WithMessage(e, "failed to parse the chromium version from the PKGBUILD")
+		return
+	} else {
+		log.Warnf("%v, version constraints can't be checked", e)
+	}
 
 	// Boiler plate for all commands
 	// ---------------------------------------------------------------------------------------------
 	chroma.printf("Chromium Ver:    %s\n", chroma.chromiumVer)
 	chroma.printf("PKBUILD Path:    %s\n", chroma.pkgbuild)
+	if sys.Exists(chroma.manifestPath()) {
+		chroma.printf("Manifest Path:   %s\n", chroma.manifestPath())
+	}
 	chroma.println()
 	return
 }
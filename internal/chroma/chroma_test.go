@@ -1,15 +1,75 @@
 package chroma
 
-import "github.com/phR0ze/n/pkg/opt"
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/phR0ze/n/pkg/opt"
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
 
 var (
 	chromiumPath = "~/Projects/cyberlinux/aur/chromium"
 )
 
 func newChroma(opts ...*opt.Opt) *Chroma {
-	opt.Add(&opts, RootOpt(chromiumPath))
+	opt.Default(&opts, RootOpt(chromiumPath))
 
 	c := New(opts...)
 
 	return c
 }
+
+// newTestPackage creates a chromium package in a temp directory with the given manifest
+// and returns a configured chroma instance for it along with the package directory.
+func newTestPackage(t *testing.T, manifest string) (c *Chroma, dir string) {
+	var err error
+	dir, err = ioutil.TempDir("", "chroma")
+	assert.Nil(t, err)
+	files := map[string]string{"PKGBUILD": "pkgname=chromium\npkgver=76.0.3809.100\n"}
+	if manifest != "" {
+		files[ManifestName] = manifest
+	}
+	writeTestFiles(t, dir, files)
+
+	c = newChroma(RootOpt(dir), opt.QuietOpt(true))
+	assert.Nil(t, c.configure())
+	return
+}
+
+// writeTestFiles writes out the given relative file paths and contents to the given directory
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for name, data := range files {
+		_, err := sys.MkdirP(path.Dir(path.Join(dir, name)))
+		assert.Nil(t, err)
+		assert.Nil(t, sys.WriteString(path.Join(dir, name), data))
+	}
+}
+
+// captureStdout returns everything the given function prints to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	reader, writer, err := os.Pipe()
+	assert.Nil(t, err)
+	stdout := os.Stdout
+	os.Stdout = writer
+	fn()
+	os.Stdout = stdout
+	writer.Close()
+	data, err := ioutil.ReadAll(reader)
+	assert.Nil(t, err)
+	return string(data)
+}
+
+// subCommand returns the given command's sub command with the given name
+func subCommand(cmd *cobra.Command, name string) *cobra.Command {
+	for _, sub := range cmd.Commands() {
+		if sub.Name() == name {
+			return sub
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package chroma
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	gRXVersionClause = regexp.MustCompile(`^(>=|<=|!=|==|=|>|<)?([0-9][0-9.]*)$`)
+)
+
+// ChromiumVersion is a four part chromium version MAJOR.MINOR.BUILD.PATCH e.g. 76.0.3809.100
+type ChromiumVersion struct {
+	Parts [4]int // version parts in order
+	Len   int    // number of parts given when parsed e.g. 1 for 80
+}
+
+// parseChromiumVersion parses the given version of one to four dot separated numbers
+func parseChromiumVersion(value string) (version *ChromiumVersion, err error) {
+	fields := strings.Split(strings.TrimSpace(value), ".")
+	if len(fields) > 4 || fields[0] == "" {
+		err = errors.Errorf("invalid chromium version %q, expected MAJOR[.MINOR[.BUILD[.PATCH]]]", value)
+		return
+	}
+	version = &ChromiumVersion{Len: len(fields)}
+	for i, field := range fields {
+		if version.Parts[i], err = strconv.Atoi(field); err != nil || version.Parts[i] < 0 {
+			version, err = nil, errors.Errorf("invalid chromium version %q, expected MAJOR[.MINOR[.BUILD[.PATCH]]]", value)
+			return
+		}
+	}
+	return
+}
+
+// String returns the version with the number of parts it was parsed with
+func (version *ChromiumVersion) String() string {
+	parts := []string{}
+	for _, part := range version.Parts[:version.Len] {
+		parts = append(parts, strconv.Itoa(part))
+	}
+	return strings.Join(parts, ".")
+}
+
+// Compare returns -1, 0 or 1 if the version is less than, equal to or greater than the other
+// version comparing only the first n parts.
+func (version *ChromiumVersion) Compare(other *ChromiumVersion, n int) int {
+	for i := 0; i < n && i < len(version.Parts); i++ {
+		switch {
+		case version.Parts[i] < other.Parts[i]:
+			return -1
+		case version.Parts[i] > other.Parts[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// VersionConstraint is a set of clauses a chromium version must all satisfy e.g. >=78.0.3904 <80.
+// Clauses only compare the parts their version gives so <80 means any 79 or earlier version and
+// =78 means any 78 version.
+type VersionConstraint []*versionClause
+
+// versionClause is a single comparison of a version constraint
+type versionClause struct {
+	Op      string           // comparison operator
+	Version *ChromiumVersion // version to compare against
+}
+
+// parseVersionConstraint parses the given space or comma separated constraint clauses
+func parseVersionConstraint(value string) (constraint VersionConstraint, err error) {
+	fields := strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == ',' || r == '\t' })
+	if len(fields) == 0 {
+		err = errors.Errorf("empty chromium version constraint")
+		return
+	}
+	for _, field := range fields {
+		match := gRXVersionClause.FindStringSubmatch(field)
+		if match == nil {
+			err = errors.Errorf("invalid chromium version constraint %q", field)
+			return
+		}
+		clause := &versionClause{Op: match[1]}
+		if clause.Op == "" || clause.Op == "==" {
+			clause.Op = "="
+		}
+		if clause.Version, err = parseChromiumVersion(match[2]); err != nil {
+			err = errors.WithMessagef(err, "invalid chromium version constraint %q", field)
+			return
+		}
+		constraint = append(constraint, clause)
+	}
+	return
+}
+
+// String returns the constraint's clauses separated by spaces
+func (constraint VersionConstraint) String() string {
+	clauses := []string{}
+	for _, clause := range constraint {
+		clauses = append(clauses, fmt.Sprintf("%s%s", clause.Op, clause.Version))
+	}
+	return strings.Join(clauses, " ")
+}
+
+// Check returns true if the given version satisfies every clause of the constraint
+func (constraint VersionConstraint) Check(version *ChromiumVersion) bool {
+	for _, clause := range constraint {
+		cmp := version.Compare(clause.Version, clause.Version.Len)
+		var ok bool
+		switch clause.Op {
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
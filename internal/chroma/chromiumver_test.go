@@ -0,0 +1,109 @@
+package chroma
+
+import (
+	"path"
+	"testing"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChromiumVersion(t *testing.T) {
+	version, err := parseChromiumVersion("78.0.3904.87")
+	assert.Nil(t, err)
+	assert.Equal(t, [4]int{78, 0, 3904, 87}, version.Parts)
+	assert.Equal(t, "78.0.3904.87", version.String())
+	other, err := parseChromiumVersion("78.0.3904.108")
+	assert.Nil(t, err)
+	assert.Equal(t, -1, version.Compare(other, 4))
+	assert.Equal(t, 0, version.Compare(other, 3))
+
+	_, err = parseChromiumVersion("78.0.x")
+	assert.Equal(t, `invalid chromium version "78.0.x", expected MAJOR[.MINOR[.BUILD[.PATCH]]]`, err.Error())
+	_, err = parseChromiumVersion("1.2.3.4.5")
+	assert.NotNil(t, err)
+}
+
+func TestVersionConstraint(t *testing.T) {
+	constraint, err := parseVersionConstraint(">=78.0.3904 <80")
+	assert.Nil(t, err)
+	assert.Equal(t, ">=78.0.3904 <80", constraint.String())
+	for value, expected := range map[string]bool{
+		"78.0.3904.0":   true,
+		"79.0.3945.130": true,
+		"78.0.3903.99":  false,
+		"80.0.3987.87":  false,
+	} {
+		version, err := parseChromiumVersion(value)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, constraint.Check(version), value)
+	}
+
+	// Partial versions compare only the given parts
+	constraint, err = parseVersionConstraint("78, <=79.0")
+	assert.Nil(t, err)
+	assert.Equal(t, "=78 <=79.0", constraint.String())
+	version, _ := parseChromiumVersion("78.0.3904.108")
+	assert.True(t, constraint.Check(version))
+
+	_, err = parseVersionConstraint("~>78")
+	assert.Equal(t, `invalid chromium version constraint "~>78"`, err.Error())
+}
+
+func TestSortVersionConstraints(t *testing.T) {
+	c, dir := newTestPackage(t, `version: 1
+patchsets:
+  team:
+    patches:
+      - name: old.patch
+        enabled: true
+        versions: <77
+      - name: new.patch
+        enabled: true
+        versions: ">=76.0.3809 <80"
+`)
+	defer sys.RemoveAll(dir)
+	patchSetDir := path.Join(dir, "patches/team")
+	writeTestFiles(t, patchSetDir, map[string]string{"00-old.patch": "old", "not-used/01-new.patch": "new"})
+
+	// The PKGBUILD packages 76.0.3809.100
+	set := c.manifest.PatchSets["team"]
+	assert.True(t, set.used("old.patch"))
+	writeTestFiles(t, dir, map[string]string{"PKGBUILD": "pkgname=chromium\npkgver=77.0.3865.75\n"})
+	assert.Nil(t, c.configure())
+	set = c.manifest.PatchSets["team"]
+	assert.False(t, set.used("old.patch"))
+	patch, _ := set.classify("old.patch")
+	assert.Equal(t, "only applies to chromium <77 not 77.0.3865.75", patch.Reason)
+
+	assert.Nil(t, c.sortPatches("team", set))
+	assert.True(t, sys.Exists(path.Join(patchSetDir, "not-used/00-old.patch")))
+	assert.True(t, sys.Exists(path.Join(patchSetDir, "01-new.patch")))
+
+	// Invalid constraints are refused
+	writeTestFiles(t, dir, map[string]string{ManifestName: "version: 1\npatchsets:\n  team:\n    patches:\n      - name: a.patch\n        versions: '>>78'\n"})
+	assert.NotNil(t, c.configure())
+}
+
+func TestConfigureChromiumVersion(t *testing.T) {
+	c, dir := newTestPackage(t, "")
+	defer sys.RemoveAll(dir)
+
+	// Quoted versions are accepted
+	writeTestFiles(t, dir, map[string]string{"PKGBUILD": "pkgname=chromium\npkgver='78.0.3904.108'\n"})
+	assert.Nil(t, c.configure())
+	assert.Equal(t, "78.0.3904.108", c.chromiumVer)
+	assert.Equal(t, "78.0.3904.108", c.manifest.PatchSets["debian"].version.String())
+
+	// Invalid versions only fail when decisions are constrained to versions
+	writeTestFiles(t, dir, map[string]string{"PKGBUILD": "pkgname=chromium\npkgver=${_ver}\n"})
+	assert.Nil(t, c.configure())
+	assert.Nil(t, c.manifest.PatchSets["debian"].version)
+	writeTestFiles(t, dir, map[string]string{ManifestName: "version: 1\npatchsets:\n  debian:\n    patches:\n      - name: a.patch\n        versions: '<80'\n"})
+	assert.NotNil(t, c.configure())
+
+	// Entries without versions keep the ones they override
+	c.manifest = &Manifest{PatchSets: map[string]*PatchSet{"team": {Patches: []*Patch{{Name: "a.patch", Versions: "<80"}}}}}
+	c.manifest.merge(&Manifest{PatchSets: map[string]*PatchSet{"team": {Patches: []*Patch{{Name: "a.patch", Enabled: true}}}}})
+	assert.Equal(t, &Patch{Name: "a.patch", Enabled: true, Versions: "<80"}, c.manifest.PatchSets["team"].patch("a.patch"))
+}
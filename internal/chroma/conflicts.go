@@ -0,0 +1,258 @@
+package chroma
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/spf13/cobra"
+)
+
+type conflictOpts struct {
+	all     bool   // include overlaps between patches of the same distribution
+	src     string // chromium source directory to check sequential application against
+	tarball string // chromium source tarball to check sequential application against
+}
+
+func (chroma *Chroma) newConflictsCmd() *cobra.Command {
+	opts := &conflictOpts{}
+	cmd := &cobra.Command{
+		Use:   "conflicts [DISTROS]",
+		Short: "Report enabled patches from different distributions that change the same lines",
+		Long: `Report enabled patches from different distributions that change the same lines.
+The hunks of every enabled patch are indexed and overlapping line ranges of the same file
+are reported. Line ranges are those of the file each patch was made against so nearby
+ranges are worth a look too.
+
+Given a chromium source directory or tarball the patches are also applied in the combined
+order to report the patches that apply on their own but no longer apply once an earlier
+patch has been applied.
+
+Examples:
+	# Report overlapping hunks between the downloaded distributions
+	chroma conflicts
+
+	# Include overlapping hunks between patches of the same distribution
+	chroma conflicts debian ungoogled --all
+
+	# Also report patches broken by earlier patches against the PKGBUILD's tarball
+	chroma conflicts --tarball chromium-76.0.3809.100.tar.xz
+`,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			if err = chroma.configure(); err != nil {
+				return
+			}
+			return chroma.conflicts(args, opts)
+		},
+	}
+	cmd.Flags().BoolVar(&opts.all, "all", false, "Include overlaps between patches of the same distribution")
+	cmd.Flags().StringVar(&opts.src, "src", "", "Chromium source directory to check the combined order against")
+	cmd.Flags().StringVar(&opts.tarball, "tarball", "", "Chromium source tarball to check the combined order against")
+	return cmd
+}
+
+// hunkRange is the original lines of a source file a patch hunk changes
+type hunkRange struct {
+	Distro string `json:"distro"` // distribution of the patch
+	Patch  string `json:"patch"`  // patch name relative to the patch set directory
+	File   string `json:"file"`   // source file the hunk changes
+	Start  int    `json:"start"`  // first original line the hunk covers
+	End    int    `json:"end"`    // last original line the hunk covers
+}
+
+// String returns the distribution qualified patch name
+func (r *hunkRange) String() string {
+	return fmt.Sprintf("%s/%s", r.Distro, r.Patch)
+}
+
+// overlaps returns true if the given range changes some of the same lines of the same file
+func (r *hunkRange) overlaps(other *hunkRange) bool {
+	return r.File == other.File && r.Start <= other.End && other.Start <= r.End
+}
+
+// Return the line ranges of the hunks of the given patch file
+func patchHunkRanges(distro, name, filepath string, strip int, reverse bool) (ranges []*hunkRange, err error) {
+	var diffs []*FileDiff
+	if diffs, err = readDiffFile(filepath); err != nil {
+		return
+	}
+	if reverse {
+		diffs = reverseDiffs(diffs)
+	}
+	for _, diff := range diffs {
+		for _, hunk := range diff.Hunks {
+			r := &hunkRange{Distro: distro, Patch: name, File: diff.Name(strip), Start: hunk.OldStart, End: hunk.OldStart}
+			if hunk.OldLines > 0 {
+				r.End = hunk.OldStart + hunk.OldLines - 1
+			}
+			ranges = append(ranges, r)
+		}
+	}
+	return
+}
+
+// Report the overlapping hunks of the enabled patches and optionally the patches broken by
+// earlier patches in the combined order.
+func (chroma *Chroma) conflicts(distros []string, opts *conflictOpts) (err error) {
+	var patches []*seriesPatch
+	if patches, err = chroma.combinedPatches(distros); err != nil {
+		return
+	}
+	var overlaps [][2]*hunkRange
+	if overlaps, err = overlappingHunks(patches, opts.all); err != nil {
+		return
+	}
+	chroma.printOverlaps(overlaps)
+
+	// Check the combined order against the source if given
+	var source sourceTree
+	switch {
+	case opts.src != "":
+		var srcDir string
+		if srcDir, err = sys.Expand(opts.src); err != nil {
+			return
+		}
+		source = &dirTree{dir: srcDir}
+	case opts.tarball != "":
+		var names map[string]bool
+		if names, err = chroma.touchedFiles(distros); err != nil {
+			return
+		}
+		if source, err = readTarball(opts.tarball, names); err != nil {
+			return
+		}
+	default:
+		chroma.printf("\nGive --src or --tarball to check for patches broken by earlier patches\n")
+		return
+	}
+	var broken []*brokenPatch
+	if broken, err = brokenPatches(source, patches); err != nil {
+		return
+	}
+	chroma.printBroken(broken)
+	return
+}
+
+// Return the pairs of overlapping hunks from different patches in file and line order. Only
+// patches of different distributions are compared unless all is set.
+func overlappingHunks(patches []*seriesPatch, all bool) (overlaps [][2]*hunkRange, err error) {
+	byFile := map[string][]*hunkRange{}
+	for _, patch := range patches {
+		var ranges []*hunkRange
+		if ranges, err = patchHunkRanges(patch.Distro, patch.Name, patch.File, patch.Strip, patch.Reverse); err != nil {
+			return
+		}
+		for _, r := range ranges {
+			byFile[r.File] = append(byFile[r.File], r)
+		}
+	}
+
+	files := []string{}
+	for file := range byFile {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		ranges := byFile[file]
+		sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+		for i, a := range ranges {
+			for _, b := range ranges[i+1:] {
+				if b.Start > a.End {
+					break
+				}
+				if a.String() != b.String() && (all || a.Distro != b.Distro) && a.overlaps(b) {
+					overlaps = append(overlaps, [2]*hunkRange{a, b})
+				}
+			}
+		}
+	}
+	return
+}
+
+// brokenPatch is a patch that doesn't apply in the combined order
+type brokenPatch struct {
+	Report   *PatchReport   // failed check in the combined order
+	Alone    bool           // true if the patch applies on its own
+	Culprits []*seriesPatch // earlier patches touching the same files
+}
+
+// Apply the given patches in order to the given source returning those that fail along with
+// whether they apply on their own and the earlier patches that touch the same files.
+func brokenPatches(source sourceTree, patches []*seriesPatch) (broken []*brokenPatch, err error) {
+	tree := newPatchedTree(source)
+	touched := map[string][]*seriesPatch{}
+	for _, patch := range patches {
+		var report *PatchReport
+		if report, err = checkPatch(tree, patch); err != nil {
+			return
+		}
+		var diffs []*FileDiff
+		if diffs, err = patch.diffs(); err != nil {
+			return
+		}
+		if report.Status == PatchFailed {
+			var alone *PatchReport
+			if alone, err = checkPatch(newPatchedTree(source), patch); err != nil {
+				return
+			}
+			b := &brokenPatch{Report: report, Alone: alone.Status != PatchFailed}
+			seen := map[*seriesPatch]bool{}
+			for _, diff := range diffs {
+				for _, culprit := range touched[diff.Name(patch.Strip)] {
+					if !seen[culprit] {
+						seen[culprit] = true
+						b.Culprits = append(b.Culprits, culprit)
+					}
+				}
+			}
+			broken = append(broken, b)
+			continue
+		}
+		for _, diff := range diffs {
+			name := diff.Name(patch.Strip)
+			touched[name] = append(touched[name], patch)
+		}
+	}
+	return
+}
+
+// Print out the given overlapping hunks
+func (chroma *Chroma) printOverlaps(overlaps [][2]*hunkRange) {
+	if len(overlaps) == 0 {
+		chroma.printf("No overlapping hunks found\n")
+		return
+	}
+	chroma.printf("Overlapping hunks:\n")
+	file := ""
+	for _, pair := range overlaps {
+		if pair[0].File != file {
+			file = pair[0].File
+			chroma.printf("  %s\n", file)
+		}
+		chroma.printf("    %s lines %d-%d <=> %s lines %d-%d\n", pair[0], pair[0].Start, pair[0].End,
+			pair[1], pair[1].Start, pair[1].End)
+	}
+}
+
+// Print out the given broken patches
+func (chroma *Chroma) printBroken(broken []*brokenPatch) {
+	chroma.println()
+	if len(broken) == 0 {
+		chroma.printf("All patches apply in the combined order\n")
+		return
+	}
+	chroma.printf("Patches that don't apply in the combined order:\n")
+	for _, b := range broken {
+		if b.Alone {
+			chroma.printf("  %s applies on its own but not after earlier patches\n", b.Report.Patch)
+		} else {
+			chroma.printf("  %s doesn't apply to the source\n", b.Report.Patch)
+		}
+		for _, note := range b.Report.Notes {
+			chroma.printf("      %s\n", note)
+		}
+		for _, culprit := range b.Culprits {
+			chroma.printf("      touched earlier by %s\n", culprit)
+		}
+	}
+}
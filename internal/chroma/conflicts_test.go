@@ -0,0 +1,48 @@
+package chroma
+
+import (
+	"path"
+	"testing"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConflicts(t *testing.T) {
+	c, dir := newTestPackage(t, "")
+	defer sys.RemoveAll(dir)
+	srcDir := path.Join(dir, "src")
+	writeTestFiles(t, srcDir, map[string]string{"app.cc": "one\ntwo\nthree\nfour\nfive\nsix\n"})
+	writeTestFiles(t, path.Join(dir, "patches"), map[string]string{
+		"debian/00-a.patch":    "--- a/app.cc\n+++ b/app.cc\n@@ -2,2 +2,2 @@\n two\n-three\n+THREE\n",
+		"debian/01-b.patch":    "--- a/app.cc\n+++ b/app.cc\n@@ -3,2 +3,2 @@\n-THREE\n+3\n four\n",
+		"ungoogled/00-c.patch": "--- a/app.cc\n+++ b/app.cc\n@@ -3 +3 @@\n-three\n+tres\n",
+		"ungoogled/01-d.patch": "--- a/app.cc\n+++ b/app.cc\n@@ -6 +6 @@\n-six\n+6\n",
+	})
+	patches, err := c.combinedPatches(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(patches))
+
+	// Only overlaps between distributions by default
+	overlaps, err := overlappingHunks(patches, false)
+	assert.Nil(t, err)
+	pairs := [][2]string{}
+	for _, pair := range overlaps {
+		pairs = append(pairs, [2]string{pair[0].String(), pair[1].String()})
+	}
+	assert.Equal(t, [][2]string{
+		{"debian/00-a.patch", "ungoogled/00-c.patch"},
+		{"debian/01-b.patch", "ungoogled/00-c.patch"},
+	}, pairs)
+	overlaps, err = overlappingHunks(patches, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(overlaps))
+
+	// Patches that apply alone but not after earlier patches name the culprits
+	broken, err := brokenPatches(&dirTree{dir: srcDir}, patches)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(broken))
+	assert.Equal(t, "ungoogled/00-c.patch", broken[0].Report.Patch.String())
+	assert.True(t, broken[0].Alone)
+	assert.Equal(t, []*seriesPatch{patches[0], patches[1]}, broken[0].Culprits)
+}
@@ -0,0 +1,354 @@
+package chroma
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DevNull is the name diffs use for the missing side of created and deleted files
+	DevNull = "/dev/null"
+
+	// MaxFuzz is the number of context lines that may be ignored at each end of a hunk
+	MaxFuzz = 2
+)
+
+var (
+	gRXHunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@ ?(.*)$`)
+)
+
+// FileDiff is the changes a patch makes to a single file
+type FileDiff struct {
+	OldName string  // name of the original file including any prefix to strip
+	NewName string  // name of the changed file including any prefix to strip
+	Binary  bool    // binary changes which can't be applied
+	Hunks   []*Hunk // changes in file order
+}
+
+// Hunk is a single block of changes to a file
+type Hunk struct {
+	OldStart int      // first line of the hunk in the original file starting from 1
+	OldLines int      // number of original lines the hunk covers
+	NewStart int      // first line of the hunk in the changed file starting from 1
+	NewLines int      // number of changed lines the hunk covers
+	Section  string   // section heading after the range e.g. a function name
+	Lines    []string // hunk lines prefixed by ' ', '-' or '+'
+	OldNoEOL bool     // original file has no newline at the end of the hunk
+	NewNoEOL bool     // changed file has no newline at the end of the hunk
+}
+
+// Created returns true if the diff creates the file
+func (diff *FileDiff) Created() bool {
+	return diff.OldName == DevNull
+}
+
+// Deleted returns true if the diff deletes the file
+func (diff *FileDiff) Deleted() bool {
+	return diff.NewName == DevNull
+}
+
+// Name returns the name of the file the diff changes with the given number of leading path
+// components stripped e.g. a/chrome/app.cc with strip 1 is chrome/app.cc.
+func (diff *FileDiff) Name(strip int) string {
+	name := diff.NewName
+	if diff.Deleted() {
+		name = diff.OldName
+	}
+	return stripPath(name, strip)
+}
+
+// Header returns the hunk header e.g. @@ -1,3 +1,4 @@
+func (hunk *Hunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", hunk.OldStart, hunk.OldLines, hunk.NewStart, hunk.NewLines)
+}
+
+// Old returns the lines the hunk expects in the original file
+func (hunk *Hunk) Old() (lines []string) {
+	for _, line := range hunk.Lines {
+		if line[0] != '+' {
+			lines = append(lines, line[1:])
+		}
+	}
+	return
+}
+
+// New returns the lines the hunk replaces the original lines with
+func (hunk *Hunk) New() (lines []string) {
+	for _, line := range hunk.Lines {
+		if line[0] != '-' {
+			lines = append(lines, line[1:])
+		}
+	}
+	return
+}
+
+// reverseDiffs returns the diffs undoing the given diffs the way patch -R applies them
+func reverseDiffs(diffs []*FileDiff) (reversed []*FileDiff) {
+	for _, diff := range diffs {
+		x := &FileDiff{OldName: diff.NewName, NewName: diff.OldName, Binary: diff.Binary}
+		for _, hunk := range diff.Hunks {
+			h := &Hunk{OldStart: hunk.NewStart, OldLines: hunk.NewLines, NewStart: hunk.OldStart, NewLines: hunk.OldLines,
+				Section: hunk.Section, OldNoEOL: hunk.NewNoEOL, NewNoEOL: hunk.OldNoEOL}
+			for _, line := range hunk.Lines {
+				switch line[0] {
+				case '+':
+					line = "-" + line[1:]
+				case '-':
+					line = "+" + line[1:]
+				}
+				h.Lines = append(h.Lines, line)
+			}
+			x.Hunks = append(x.Hunks, h)
+		}
+		reversed = append(reversed, x)
+	}
+	return
+}
+
+// parseDiff parses the unified diff from the given reader. Anything outside of the file diffs
+// e.g. mail headers, commit messages and DEP-3 headers is skipped.
+func parseDiff(reader io.Reader) (diffs []*FileDiff, err error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSuffix(scanner.Text(), "\r"))
+	}
+	if err = scanner.Err(); err != nil {
+		err = errors.Wrap(err, "failed to read diff")
+		return
+	}
+
+	var diff *FileDiff
+	var gitNames []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+
+		// Binary changes are recorded against the names of the git header so they can be reported
+		case strings.HasPrefix(line, "diff --git "):
+			diff, gitNames = nil, strings.Fields(strings.TrimPrefix(line, "diff --git "))
+		case strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch":
+			if diff == nil {
+				diff = &FileDiff{}
+				diffs = append(diffs, diff)
+				if len(gitNames) == 2 {
+					diff.OldName, diff.NewName = gitNames[0], gitNames[1]
+				}
+			}
+			diff.Binary = true
+
+		// New file diff
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			diff = &FileDiff{OldName: diffFileName(line[4:]), NewName: diffFileName(lines[i+1][4:])}
+			diffs = append(diffs, diff)
+			i++
+
+		// Hunk of the current file diff
+		case strings.HasPrefix(line, "@@ "):
+			if diff == nil {
+				err = errors.Errorf("line %d: hunk without a file header", i+1)
+				return
+			}
+			var hunk *Hunk
+			if hunk, i, err = parseHunk(lines, i); err != nil {
+				return
+			}
+			diff.Hunks = append(diff.Hunks, hunk)
+		}
+	}
+	return
+}
+
+// Parse the hunk starting at the given line index returning the index of its last line
+func parseHunk(lines []string, i int) (hunk *Hunk, end int, err error) {
+	match := gRXHunkHeader.FindStringSubmatch(lines[i])
+	if match == nil {
+		err = errors.Errorf("line %d: invalid hunk header %q", i+1, lines[i])
+		return
+	}
+	hunk = &Hunk{Section: match[5]}
+	hunk.OldStart, _ = strconv.Atoi(match[1])
+	hunk.OldLines = hunkLines(match[2])
+	hunk.NewStart, _ = strconv.Atoi(match[3])
+	hunk.NewLines = hunkLines(match[4])
+
+	// Read lines until both sides are complete as content may look like headers
+	oldLeft, newLeft := hunk.OldLines, hunk.NewLines
+	end = i
+	for oldLeft > 0 || newLeft > 0 {
+		if end++; end >= len(lines) {
+			err = errors.Errorf("line %d: truncated hunk %s", i+1, hunk.Header())
+			return
+		}
+		line := lines[end]
+		if line == "" {
+			line = " " // some tools strip the space from empty context lines
+		}
+		switch line[0] {
+		case ' ':
+			oldLeft, newLeft = oldLeft-1, newLeft-1
+		case '-':
+			oldLeft--
+		case '+':
+			newLeft--
+		case '\\':
+			hunk.noEOL()
+			continue
+		default:
+			err = errors.Errorf("line %d: invalid hunk line %q", end+1, line)
+			return
+		}
+		if oldLeft < 0 || newLeft < 0 {
+			err = errors.Errorf("line %d: hunk %s has more lines than its header", end+1, hunk.Header())
+			return
+		}
+		hunk.Lines = append(hunk.Lines, line)
+	}
+
+	// Note a missing newline at the end of the last line
+	if end+1 < len(lines) && strings.HasPrefix(lines[end+1], `\`) {
+		end++
+		hunk.noEOL()
+	}
+	return
+}
+
+// noEOL notes that the last hunk line read has no newline at the end of the file
+func (hunk *Hunk) noEOL() {
+	if len(hunk.Lines) == 0 {
+		return
+	}
+	switch hunk.Lines[len(hunk.Lines)-1][0] {
+	case '-':
+		hunk.OldNoEOL = true
+	case '+':
+		hunk.NewNoEOL = true
+	default:
+		hunk.OldNoEOL, hunk.NewNoEOL = true, true
+	}
+}
+
+// hunkLines parses the optional hunk line count which defaults to one
+func hunkLines(count string) int {
+	if count == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(count)
+	return n
+}
+
+// diffFileName returns the file name from a ---/+++ header line dropping any timestamp
+func diffFileName(header string) string {
+	if i := strings.Index(header, "\t"); i != -1 {
+		header = header[:i]
+	}
+	return strings.TrimSpace(header)
+}
+
+// stripPath removes the given number of leading components from the given path
+func stripPath(name string, strip int) string {
+	for ; strip > 0; strip-- {
+		i := strings.Index(name, "/")
+		if i == -1 {
+			break
+		}
+		name = name[i+1:]
+	}
+	return name
+}
+
+// HunkResult is the outcome of applying a single hunk
+type HunkResult struct {
+	Hunk    *Hunk // hunk applied
+	Applied bool  // true if the hunk applied
+	Offset  int   // lines the hunk was found away from where it expected
+	Fuzz    int   // context lines ignored at each end to apply the hunk
+}
+
+// applyHunks applies the given hunks in order to the given file lines with offset and fuzz
+// handling like GNU patch. The changed lines are returned along with the result for each
+// hunk. Hunks are located nearest to where they expect first and never before the end of
+// the previously applied hunk.
+func applyHunks(lines []string, hunks []*Hunk) (result []string, results []*HunkResult) {
+	result = append([]string{}, lines...)
+	offset, start := 0, 0
+	for _, hunk := range hunks {
+		res := &HunkResult{Hunk: hunk}
+		results = append(results, res)
+		oldLines, newLines := hunk.Old(), hunk.New()
+		for fuzz := 0; fuzz <= MaxFuzz && !res.Applied; fuzz++ {
+			head, tail := hunkContext(hunk, fuzz)
+			if fuzz > 0 && head == 0 && tail == 0 {
+				break
+			}
+			want := oldLines[head : len(oldLines)-tail]
+			expected := hunk.OldStart - 1 + offset + head
+			if hunk.OldLines == 0 {
+				expected = hunk.OldStart + offset // insertion after the given line
+			}
+			if pos, ok := findLines(result, want, expected, start); ok {
+				replaced := newLines[head : len(newLines)-tail]
+				result = append(result[:pos], append(append([]string{}, replaced...), result[pos+len(want):]...)...)
+				res.Applied, res.Fuzz, res.Offset = true, fuzz, pos-expected
+				offset += res.Offset + len(replaced) - len(want)
+				start = pos + len(replaced)
+			}
+		}
+	}
+	return
+}
+
+// hunkContext returns the number of leading and trailing context lines to drop for the given
+// fuzz factor which is limited by the context lines actually at each end of the hunk.
+func hunkContext(hunk *Hunk, fuzz int) (head, tail int) {
+	for head < fuzz && head < len(hunk.Lines) && hunk.Lines[head][0] == ' ' {
+		head++
+	}
+	for tail < fuzz && tail < len(hunk.Lines)-head && hunk.Lines[len(hunk.Lines)-1-tail][0] == ' ' {
+		tail++
+	}
+	return
+}
+
+// findLines returns the position of the given lines in the given file lines searching
+// outwards from the expected position but not before the given start position.
+func findLines(lines, want []string, expected, start int) (pos int, ok bool) {
+	if expected < start {
+		expected = start
+	}
+	if expected > len(lines)-len(want) {
+		expected = len(lines) - len(want)
+	}
+	for distance := 0; ; distance++ {
+		before, after := expected-distance, expected+distance
+		if before < start && after > len(lines)-len(want) {
+			return
+		}
+		if after <= len(lines)-len(want) && after >= start && matchLines(lines, want, after) {
+			return after, true
+		}
+		if distance > 0 && before >= start && matchLines(lines, want, before) {
+			return before, true
+		}
+	}
+}
+
+// matchLines returns true if the given lines are found at the given position
+func matchLines(lines, want []string, pos int) bool {
+	if pos < 0 || pos+len(want) > len(lines) {
+		return false
+	}
+	for i, line := range want {
+		if lines[pos+i] != line {
+			return false
+		}
+	}
+	return true
+}
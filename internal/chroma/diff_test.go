@@ -0,0 +1,85 @@
+package chroma
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPatch = `From 1234567890abcdef Mon Sep 17 00:00:00 2001
+From: Dev <dev@example.com>
+Subject: [PATCH] Fix the build
+
+--- a/chrome/app.cc
++++ b/chrome/app.cc
+@@ -2,4 +2,5 @@ int main() {
+ two
+ three
+-four
++FOUR
++four and a half
+ five
+--- /dev/null	2019-08-01 00:00:00
++++ b/chrome/new.h	2019-08-01 00:00:00
+@@ -0,0 +1,2 @@
++// new
++int x;
+\ No newline at end of file
+-- 
+2.22.0
+`
+
+func TestParseDiff(t *testing.T) {
+	diffs, err := parseDiff(strings.NewReader(testPatch))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(diffs))
+
+	assert.Equal(t, "chrome/app.cc", diffs[0].Name(1))
+	assert.Equal(t, "b/chrome/app.cc", diffs[0].Name(0))
+	assert.Equal(t, 1, len(diffs[0].Hunks))
+	hunk := diffs[0].Hunks[0]
+	assert.Equal(t, "@@ -2,4 +2,5 @@", hunk.Header())
+	assert.Equal(t, "int main() {", hunk.Section)
+	assert.Equal(t, []string{"two", "three", "four", "five"}, hunk.Old())
+	assert.Equal(t, []string{"two", "three", "FOUR", "four and a half", "five"}, hunk.New())
+
+	assert.True(t, diffs[1].Created())
+	assert.Equal(t, "chrome/new.h", diffs[1].Name(1))
+	assert.True(t, diffs[1].Hunks[0].NewNoEOL)
+
+	// Hunks must have as many lines as their header says
+	_, err = parseDiff(strings.NewReader("--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n-one\n+uno\n"))
+	assert.Equal(t, "line 3: truncated hunk @@ -1,2 +1,2 @@", err.Error())
+}
+
+func TestApplyHunks(t *testing.T) {
+	diffs, err := parseDiff(strings.NewReader(testPatch))
+	assert.Nil(t, err)
+	hunks := diffs[0].Hunks
+
+	// Clean
+	lines, results := applyHunks([]string{"one", "two", "three", "four", "five", "six"}, hunks)
+	assert.Equal(t, []string{"one", "two", "three", "FOUR", "four and a half", "five", "six"}, lines)
+	assert.Equal(t, &HunkResult{Hunk: hunks[0], Applied: true}, results[0])
+
+	// Offset
+	lines, results = applyHunks([]string{"zero", "zero", "one", "two", "three", "four", "five"}, hunks)
+	assert.Equal(t, "FOUR", lines[5])
+	assert.Equal(t, &HunkResult{Hunk: hunks[0], Applied: true, Offset: 2}, results[0])
+
+	// Fuzz ignores changed context at the ends
+	lines, results = applyHunks([]string{"one", "TWO", "three", "four", "FIVE"}, hunks)
+	assert.Equal(t, []string{"one", "TWO", "three", "FOUR", "four and a half", "FIVE"}, lines)
+	assert.Equal(t, &HunkResult{Hunk: hunks[0], Applied: true, Fuzz: 1}, results[0])
+
+	// Failure leaves the lines alone
+	lines, results = applyHunks([]string{"one", "two", "three", "4", "five"}, hunks)
+	assert.Equal(t, []string{"one", "two", "three", "4", "five"}, lines)
+	assert.False(t, results[0].Applied)
+
+	// Created files
+	lines, results = applyHunks(nil, diffs[1].Hunks)
+	assert.Equal(t, []string{"// new", "int x;"}, lines)
+	assert.True(t, results[0].Applied)
+}
@@ -0,0 +1,78 @@
+package chroma
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/pkg/errors"
+)
+
+// dirSource provides the patches in a local directory. The order is taken from the
+// directory's series file if it has one else from the sorted patch file paths.
+type dirSource struct {
+	dir string // directory containing the patches
+}
+
+func newDirSource(opts *PatchSourceOpts) (source PatchSource, err error) {
+	set := opts.Set
+	var dir string
+	if dir, err = sys.Expand(set.URL); err != nil {
+		return
+	}
+	if !path.IsAbs(dir) {
+		dir = path.Join(opts.RootDir, dir)
+	}
+	dir = path.Join(dir, set.Path)
+	if !sys.IsDir(dir) {
+		err = errors.Errorf("patch directory %s doesn't exist", dir)
+		return
+	}
+	source = &dirSource{dir: dir}
+	return
+}
+
+// Entries returns the patches listed in the series file or found in the directory
+func (source *dirSource) Entries() (entries []*PatchEntry, err error) {
+	seriesFile := path.Join(source.dir, "series")
+	if sys.Exists(seriesFile) {
+		return readSeriesFile(seriesFile)
+	}
+
+	// Discover the patches in the directory skipping hidden directories
+	var patches []string
+	err = filepath.Walk(source.dir, func(target string, info os.FileInfo, e error) error {
+		if e != nil {
+			return e
+		}
+		if info.IsDir() && target != source.dir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && path.Ext(target) == ".patch" {
+			patches = append(patches, strings.TrimPrefix(target, source.dir+"/"))
+		}
+		return nil
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed to discover patches in %s", source.dir)
+		return
+	}
+	if len(patches) == 0 {
+		err = errors.Errorf("no patches found in %s", source.dir)
+		return
+	}
+	sort.Strings(patches)
+	for _, patch := range patches {
+		entries = append(entries, &PatchEntry{Path: patch, Strip: DefaultStrip})
+	}
+	return
+}
+
+// Fetch copies the given patch from the directory
+func (source *dirSource) Fetch(entry *PatchEntry, dst string) (err error) {
+	_, err = sys.CopyFile(path.Join(source.dir, entry.Path), dst)
+	return
+}
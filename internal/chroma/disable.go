@@ -0,0 +1,32 @@
+package chroma
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func (chroma *Chroma) newDisableCmd() *cobra.Command {
+	reason := ""
+	cmd := &cobra.Command{
+		Use:   "disable DISTRO PATCH...",
+		Short: "Disable patches and persist the decision to the manifest",
+		Long: `Disable patches and persist the decision to the manifest. The decision and the
+required reason are written to the chroma.yaml manifest next to the PKGBUILD and the downloaded
+patches are sorted to match. Patches are named by upstream path, local file name or name
+without the order number.
+
+Examples:
+	# Disable a debian patch
+	chroma disable debian system/vpx.patch --reason "we don't want rebuilds on libvpx updates"
+`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			if err = chroma.configure(); err != nil {
+				return
+			}
+			return chroma.setPatches(args[0], args[1:], false, reason)
+		},
+	}
+	cmd.Flags().StringVar(&reason, "reason", "", "Rationale for disabling the patches")
+	cmd.MarkFlagRequired("reason")
+	return cmd
+}
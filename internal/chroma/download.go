@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"net/url"
 	"path"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/phR0ze/n"
 	"github.com/phR0ze/n/pkg/arch/zip"
-	"github.com/phR0ze/n/pkg/net"
 	"github.com/phR0ze/n/pkg/net/mech"
 	"github.com/phR0ze/n/pkg/sys"
 	"github.com/pkg/errors"
@@ -16,7 +18,13 @@ import (
 )
 
 type downloadOpts struct {
-	clean bool // remove previous files before downloading
+	clean          bool          // remove previous files before downloading
+	source         string        // patch source type to use rather than the patch set's
+	keepPaths      bool          // keep the upstream relative paths rather than flattening
+	refresh        bool          // revalidate the series and patches upstream regardless of age
+	maxAge         time.Duration // how long a synced patch set is used before being revalidated
+	failOnUnmapped bool          // fail if any downloaded patches aren't classified in the manifest
+	pruneOpts
 }
 
 func (chroma *Chroma) newDownloadCmd() *cobra.Command {
@@ -29,7 +37,7 @@ func (chroma *Chroma) newDownloadCmd() *cobra.Command {
 			return cmd.Help()
 		},
 	}
-	cmd.Flags().BoolVar(&opts.clean, "clean", false, "Remove local files before downloading")
+	cmd.PersistentFlags().BoolVar(&opts.clean, "clean", false, "Remove local files before downloading")
 	cmd.AddCommand(
 		func() *cobra.Command {
 			cmd := &cobra.Command{
@@ -72,9 +80,32 @@ Examples:
 	
 	# Download the debian and ungoogled patches
 	chroma down patches debian ungoogled
+
+	# Download the inox patches discovered in the inox repository
+	chroma down patches inox
+
+	# Download the iridium patches exported from the iridium commit series
+	chroma down patches iridium
+
+	# Download a team patch set from a local directory declared in the manifest
+	chroma down patches team --source dir
+
+	# Download the ungoogled patches keeping the upstream core/ and extra/ directories
+	chroma down patches ungoogled --keep-paths
+
+	# Check upstream for added, removed or changed debian patches since the last sync
+	chroma down patches debian --refresh
+
+	# Revalidate the patches upstream if they were last synced more than an hour ago
+	chroma down patches --max-age 1h
+
+	# Move debian patches upstream has dropped from the series to patches/debian/obsolete
+	chroma down patches debian --prune
+
+	# Fail if any upstream patches haven't been classified as enabled or disabled e.g. in CI
+	chroma down patches --fail-on-unmapped
 `,
 				Aliases: []string{"pa", "patch"},
-				Args:    cobra.MinimumNArgs(1),
 				RunE: func(cmd *cobra.Command, args []string) (err error) {
 					if err = chroma.configure(); err != nil {
 						return
@@ -85,12 +116,24 @@ Examples:
 					return
 				},
 			}
+			opts.addPatchFlags(cmd)
 			return cmd
 		}(),
 	)
 	return cmd
 }
 
+// Add the flags controlling how patches are downloaded to the given command
+func (opts *downloadOpts) addPatchFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&opts.keepPaths, "keep-paths", false, "Keep upstream series sub directories rather than numbering and flattening")
+	cmd.Flags().StringVar(&opts.source, "source", "", fmt.Sprintf("Patch source type to use rather than the manifest's %v", PatchSourceKinds()))
+	cmd.Flags().BoolVar(&opts.refresh, "refresh", false, "Revalidate the series and patches upstream regardless of when they were last synced")
+	cmd.Flags().DurationVar(&opts.maxAge, "max-age", DefaultMaxAge, "Revalidate upstream when the last sync is older than this, 0 to never")
+	cmd.Flags().BoolVar(&opts.prune, "prune", false, fmt.Sprintf("Move patches no longer in the series to the %s directory", ObsoleteDir))
+	cmd.Flags().BoolVar(&opts.delete, "delete", false, "Delete pruned patches rather than moving them")
+	cmd.Flags().BoolVar(&opts.failOnUnmapped, "fail-on-unmapped", false, "Fail if any patches aren't classified as enabled or disabled")
+}
+
 // Download the given extension from the Google Market
 func (chroma *Chroma) downloadExtensions(extnames []string, opts *downloadOpts) (err error) {
 	log.Infof("Downloading extensions => %s", chroma.extensionsDir)
@@ -98,10 +141,10 @@ func (chroma *Chroma) downloadExtensions(extnames []string, opts *downloadOpts)
 
 	// Select extensions is given
 	if len(extnames) == 0 {
-		exts = gExtensions
+		exts = chroma.manifest.Extensions
 	} else {
 		for _, name := range extnames {
-			if val, ok := gExtensions[name]; ok {
+			if val, ok := chroma.manifest.Extensions[name]; ok {
 				exts[name] = val
 			} else {
 				err = errors.Errorf("Error: unsupported extension %s", name)
@@ -112,24 +155,29 @@ func (chroma *Chroma) downloadExtensions(extnames []string, opts *downloadOpts)
 
 	// Ensure destination directory is clean and ready
 	// -----------------------------------------------------------------------------------------
-	if opts.clean {
+	if opts.clean && chroma.exists(chroma.extensionsDir) {
 		log.Infof("Removing all files in local extensions dir %s", chroma.extensionsDir)
-		if sys.Exists(chroma.extensionsDir) {
-			sys.RemoveAll(chroma.extensionsDir)
+		if err = chroma.remove(chroma.extensionsDir); err != nil {
+			return
 		}
 	}
-	if _, err = sys.MkdirP(chroma.extensionsDir); err != nil {
+	if err = chroma.mkdir(chroma.extensionsDir); err != nil {
 		return
 	}
 
-	// Download and process links from the patchset page
+	// Download and process links from the patchset page in name order
 	// -----------------------------------------------------------------------------------------
-	agent := mech.New()
-	for extName, extID := range exts {
+	names := []string{}
+	for extName := range exts {
+		names = append(names, extName)
+	}
+	sort.Strings(names)
+	for _, extName := range names {
+		extID := exts[extName]
 		crxfile := path.Join(chroma.extensionsDir, fmt.Sprintf("%s.crx", extName))
 
 		// Download the extension if it doesn't yet exist
-		if !sys.Exists(crxfile) {
+		if !chroma.exists(crxfile) {
 			log.Infof("Downloading extension %s:%s => %s", extName, extID, sys.SlicePath(crxfile, -3, -1))
 			var uri *url.URL
 			if uri, err = url.Parse("https://clients2.google.com/service/update2/crx"); err != nil {
@@ -142,52 +190,19 @@ func (chroma *Chroma) downloadExtensions(extnames []string, opts *downloadOpts)
 				"prodversion": {chroma.chromiumVer},
 				"x":           {fmt.Sprintf("id=%s&installsource=ondemand&uc", extID)},
 			}.Encode()
-			if _, err = agent.Download(uri.String(), crxfile); err != nil {
+			if err = chroma.downloadURL(uri.String(), crxfile); err != nil {
 				return
 			}
 		}
 
 		// Generate the JSON preferences file
 		prefPath := path.Join(chroma.extensionsDir, fmt.Sprintf("%s.json", extID))
-		if !sys.Exists(prefPath) {
+		if !chroma.exists(prefPath) {
 			log.Infof("Generating extension preferences file for %s", extName)
-
-			// Unzip the extension
-			tmpDir := path.Join(chroma.extensionsDir, "_tmp")
-			log.Infof("Unzipping the extension %s => %s", extName, sys.SlicePath(tmpDir, -3, -1))
-			if sys.Exists(tmpDir) {
-				sys.RemoveAll(tmpDir)
-			}
-			if err = zip.ExtractAll(crxfile, tmpDir); err != nil {
-				return
-			}
-
-			// Read in the extension's manifest.json file
-			var m *n.StringMap
-			jsonfile := path.Join(tmpDir, "manifest.json")
-			if m, err = n.LoadJSONE(jsonfile); err != nil {
-				return
-			}
-			extVer := m.Query("version").A()
-			if extVer == "" {
-				err = errors.Errorf("failed to extract version from ext manifest file")
+			action := &Action{Kind: ActionGenerate, Src: crxfile, Dst: prefPath}
+			if err = chroma.perform(action, func() error { return generateExtensionPrefs(crxfile, prefPath) }); err != nil {
 				return
 			}
-			log.Infof("Extracted extension version: %s", extVer)
-
-			// Preferences file
-			// https://developer.chrome.com/apps/external_extensions
-			prefs := n.NewStringMap(map[string]interface{}{
-				"external_crx":     path.Join("/usr/share/chromium/extensions", path.Base(crxfile)),
-				"external_version": extVer,
-				// Rather than the local file external_crx we can use the upate url below to download them
-				//"external_update_url": "https://clients2.google.com/service/update2/crx",
-			})
-			log.Infof("Creating preference file %s", sys.SlicePath(prefPath, -3, -1))
-			if err = prefs.WriteJSON(prefPath); err != nil {
-				return
-			}
-			sys.RemoveAll(tmpDir)
 		} else {
 			log.Infof("Extension preferences file for %s already exists", extName)
 		}
@@ -196,127 +211,303 @@ func (chroma *Chroma) downloadExtensions(extnames []string, opts *downloadOpts)
 	return
 }
 
+// Download the given url to the given destination
+func (chroma *Chroma) downloadURL(uri, dst string) (err error) {
+	return chroma.perform(&Action{Kind: ActionDownload, Src: uri, Dst: dst}, func() (err error) {
+		_, err = mech.New().Download(uri, dst)
+		return
+	})
+}
+
+// Generate the JSON preferences file for the given extension from its manifest
+func generateExtensionPrefs(crxfile, prefPath string) (err error) {
+	extName := strings.TrimSuffix(path.Base(crxfile), ".crx")
+
+	// Unzip the extension
+	tmpDir := path.Join(path.Dir(crxfile), "_tmp")
+	log.Infof("Unzipping the extension %s => %s", extName, sys.SlicePath(tmpDir, -3, -1))
+	if sys.Exists(tmpDir) {
+		sys.RemoveAll(tmpDir)
+	}
+	if err = zip.ExtractAll(crxfile, tmpDir); err != nil {
+		return
+	}
+	defer sys.RemoveAll(tmpDir)
+
+	// Read in the extension's manifest.json file
+	var m *n.StringMap
+	jsonfile := path.Join(tmpDir, "manifest.json")
+	if m, err = n.LoadJSONE(jsonfile); err != nil {
+		return
+	}
+	extVer := m.Query("version").A()
+	if extVer == "" {
+		err = errors.Errorf("failed to extract version from ext manifest file")
+		return
+	}
+	log.Infof("Extracted extension version: %s", extVer)
+
+	// Preferences file
+	// https://developer.chrome.com/apps/external_extensions
+	prefs := n.NewStringMap(map[string]interface{}{
+		"external_crx":     path.Join("/usr/share/chromium/extensions", path.Base(crxfile)),
+		"external_version": extVer,
+		// Rather than the local file external_crx we can use the upate url below to download them
+		//"external_update_url": "https://clients2.google.com/service/update2/crx",
+	})
+	log.Infof("Creating preference file %s", sys.SlicePath(prefPath, -3, -1))
+	return prefs.WriteJSON(prefPath)
+}
+
 // Download patches for the given distributions
 func (chroma *Chroma) downloadPatches(distros []string, opts *downloadOpts) (err error) {
 	if len(distros) == 0 {
-		distros = []string{"debian", "ungoogled"}
+		distros = gDefaultDistros
 	}
+	unmapped := 0
 	for _, distro := range distros {
-		patchSetDir := path.Join(chroma.patchesDir, distro)
-		notUsedDir := path.Join(patchSetDir, "not-used")
-
-		// Ensure destination directory is clean and ready
-		// -----------------------------------------------------------------------------------------
-		if opts.clean {
-			log.Infof("Removing all files in local patchset dir %s", patchSetDir)
-			if sys.Exists(patchSetDir) {
-				sys.RemoveAll(patchSetDir)
-			}
+		set, ok := chroma.manifest.PatchSets[distro]
+		if !ok {
+			err = errors.Errorf("Error: unsupported patch set %s", distro)
+			return
 		}
-		if _, err = sys.MkdirP(notUsedDir); err != nil {
+		var count int
+		if count, err = chroma.downloadPatchSet(distro, set, opts); err != nil {
 			return
 		}
+		unmapped += count
+	}
+	if opts.failOnUnmapped && unmapped > 0 {
+		err = errors.Errorf("Error: %d patches aren't classified as enabled or disabled", unmapped)
+		return
+	}
+	return
+}
 
-		// Download and process links from the patchset page
-		// -----------------------------------------------------------------------------------------
-		log.Infof("Downloading patchset %s => %s", distro, patchSetDir)
-		agent := mech.New()
-
-		// Handle each distro differently
-		// -----------------------------------------------------------------------------------------
-		switch distro {
-		case "debian":
-			var order *n.StringSlice
-			if order, err = readOrderFile(distro, patchSetDir); err != nil {
-				return
-			}
+// Download the patches for the given distribution's patch set returning the number of
+// patches that aren't classified as enabled or disabled.
+func (chroma *Chroma) downloadPatchSet(distro string, set *PatchSet, opts *downloadOpts) (unmapped int, err error) {
+	patchSetDir := path.Join(chroma.patchesDir, distro)
+	notUsedDir := path.Join(patchSetDir, NotUsedDir)
 
-			// Download each of the patches numbering and naming them according to the order file
-			for i, entry := range order.ToStrs() {
-				uri := net.JoinURL(net.DirURL(gPatchSets[distro]), entry)
-				dstName := fmt.Sprintf("%02d-%s", i, path.Base(entry))
-				if err = downloadPatch(agent, uri, distro, patchSetDir, dstName); err != nil {
-					return
-				}
+	// Ensure destination directory is clean and ready
+	// ---------------------------------------------------------------------------------------------
+	if opts.clean && chroma.exists(patchSetDir) {
+		log.Infof("Removing all files in local patchset dir %s", patchSetDir)
+		if err = chroma.remove(patchSetDir); err != nil {
+			return
+		}
+	}
+	if err = chroma.mkdir(notUsedDir); err != nil {
+		return
+	}
+	cacheDir, cleanup, err := chroma.cacheDir(patchSetDir)
+	if err != nil {
+		return
+	}
+	defer cleanup()
+
+	// Revalidate upstream when asked to or the last sync is too old
+	// ---------------------------------------------------------------------------------------------
+	var state *syncState
+	if state, err = loadSyncState(cacheDir); err != nil {
+		return
+	}
+	refresh := opts.refresh || state.stale(opts.maxAge)
+	if refresh {
+		log.Infof("Revalidating patchset %s upstream", distro)
+	}
+
+	// Download and process the patches in the order given by the patch source. Cloned sources
+	// aren't updated when making no changes as the clone is shared with the package.
+	// ---------------------------------------------------------------------------------------------
+	log.Infof("Downloading patchset %s => %s", distro, patchSetDir)
+	if opts.source != "" {
+		if chroma.sources == nil {
+			chroma.sources = map[string]string{}
+		}
+		chroma.sources[distro] = opts.source
+	}
+	var source PatchSource
+	fetcher := newFetcher(state, refresh)
+	fetcher.cache = chroma.cacheWriter(cacheDir, patchSetDir)
+	sourceOpts := &PatchSourceOpts{Set: set, PatchSetDir: cacheDir, RootDir: chroma.rootDir,
+		Refresh: refresh && !chroma.dryrun, fetcher: fetcher}
+	if source, err = newPatchSource(opts.source, sourceOpts); err != nil {
+		err = errors.WithMessagef(err, "failed to create patch source for %s", distro)
+		return
+	}
+	var entries []*PatchEntry
+	if entries, err = source.Entries(); err != nil {
+		return
+	}
+
+	// Index the existing numbered patches by identity to detect upstream renumbering
+	keepPaths := opts.keepPaths || set.KeepPaths
+	numbered := map[string]*localPatch{}
+	if !keepPaths {
+		var patches []*localPatch
+		if patches, err = chroma.localPatches(patchSetDir); err != nil {
+			return
+		}
+		for _, patch := range patches {
+			if path.Dir(patch.Name) == "." {
+				numbered[patchID(patch.Name)] = patch
 			}
-		case "ungoogled":
-			var order *n.StringSlice
-			if order, err = readOrderFile(distro, patchSetDir); err != nil {
+		}
+	}
+
+	// Download each of the patches either keeping the upstream path or numbering and naming
+	// them according to the order. Either way the patch is identified by its upstream path.
+	changes := state.diff(entries)
+	for i, entry := range entries {
+		dstName := patchFileName(i, entry, keepPaths)
+		if patch, ok := numbered[path.Base(entry.Path)]; ok {
+			if err = chroma.renumberPatch(patch, patchSetDir, dstName); err != nil {
 				return
 			}
+		}
+		var changed bool
+		if changed, err = chroma.downloadPatch(source, distro, entry, set, patchSetDir, dstName, refresh); err != nil {
+			return
+		}
+		if changed {
+			changes.Changed = append(changes.Changed, entry.Path)
+		}
+	}
 
-			// Download each of the patches numbering and naming them according to the order file
-			for i, entry := range order.ToStrs() {
-				uri := net.JoinURL(net.DirURL(gPatchSets[distro]), entry)
-				dstName := fmt.Sprintf("%02d-%s", i, path.Base(entry))
-				if err = downloadPatch(agent, uri, distro, patchSetDir, dstName); err != nil {
-					return
-				}
-			}
+	// Report the upstream changes since the last sync and record this one
+	// ---------------------------------------------------------------------------------------------
+	if len(state.Series) > 0 {
+		chroma.printSyncChanges(distro, changes)
+	}
+	state.record(entries)
+	if refresh {
+		state.Synced = time.Now()
+	}
+	if err = chroma.saveSyncState(patchSetDir, state); err != nil {
+		return
+	}
+
+	// List and prune the local patches upstream has dropped
+	// ---------------------------------------------------------------------------------------------
+	if err = chroma.prunePatches(distro, state.Series, &opts.pruneOpts); err != nil {
+		return
+	}
+
+	// Report the patches that haven't been classified as enabled or disabled
+	// ---------------------------------------------------------------------------------------------
+	unmapped = chroma.reportUnmapped(distro, set, entries)
+	return
+}
+
+// Print out the given entries that aren't classified in the given patch set returning the count.
+// Unmapped patches are left disabled until they are added to the manifest.
+func (chroma *Chroma) reportUnmapped(distro string, set *PatchSet, entries []*PatchEntry) (count int) {
+	for _, entry := range entries {
+		if set.mapped(entry.Path) {
+			continue
 		}
+		if count == 0 {
+			chroma.printf("Unclassified %s patches left disabled, add them to the %s manifest:\n", distro, ManifestName)
+		}
+		chroma.printf("  ? %s\n", entry.Path)
+		count++
 	}
 	return
 }
 
-// Download the given patch set or relocate it if needed
-func downloadPatch(agent *mech.Mech, uri, distro, patchSetDir, dstName string) (err error) {
+// Print out the given upstream changes for the given distribution
+func (chroma *Chroma) printSyncChanges(distro string, changes *syncChanges) {
+	if changes.empty() {
+		chroma.printf("No upstream changes to the %s patches since the last sync\n", distro)
+		return
+	}
+	chroma.printf("Upstream changes to the %s patches since the last sync:\n", distro)
+	for _, name := range changes.Added {
+		chroma.printf("  + %s\n", name)
+	}
+	for _, name := range changes.Removed {
+		chroma.printf("  - %s\n", name)
+	}
+	for _, name := range changes.Changed {
+		chroma.printf("  ~ %s\n", name)
+	}
+}
+
+// Download the given patch set or relocate it if needed. When refreshing a patch that already
+// exists it is revalidated upstream and changed is returned true if its content differs.
+func (chroma *Chroma) downloadPatch(source PatchSource, distro string, entry *PatchEntry, set *PatchSet,
+	patchSetDir, dstName string, refresh bool) (changed bool, err error) {
 
 	// Set path name to used or not used
 	dstUsedPath := path.Join(patchSetDir, dstName)
-	dstNotUsedPath := path.Join(patchSetDir, "not-used", dstName)
-	used := gPatches[distro][dstName]
+	dstNotUsedPath := path.Join(patchSetDir, NotUsedDir, dstName)
+	used := set.used(entry.Path)
+	dstPath := dstUsedPath
+	if !used {
+		dstPath = dstNotUsedPath
+	}
 	switch {
 
 	// Move not used file from used to not used directory
-	case !used && sys.Exists(dstUsedPath):
+	case !used && chroma.exists(dstUsedPath):
 		log.Infof("Disabling patch %s => %s", dstName, sys.SlicePath(dstUsedPath, -3, -1))
-		if _, err = sys.Move(dstUsedPath, dstNotUsedPath); err != nil {
+		if err = chroma.move(dstUsedPath, dstNotUsedPath); err != nil {
 			return
 		}
 
 	// Move used file from not used to used directory
-	case used && sys.Exists(dstNotUsedPath):
+	case used && chroma.exists(dstNotUsedPath):
 		log.Infof("Enabling patch %s => %s", dstName, sys.SlicePath(dstUsedPath, -3, -1))
-		if _, err = sys.Move(dstNotUsedPath, dstUsedPath); err != nil {
+		if err = chroma.move(dstNotUsedPath, dstUsedPath); err != nil {
 			return
 		}
 
-	case !sys.Exists(dstUsedPath) && !sys.Exists(dstNotUsedPath):
-		dstPath := dstUsedPath
-		if !used {
-			dstPath = dstNotUsedPath
-		}
-		log.Infof("Downloading patch %s => %s", sys.SlicePath(uri, -3, -1), sys.SlicePath(dstPath, -2, -1))
-		if _, err = agent.Download(uri, dstPath); err != nil {
+	case !chroma.exists(dstUsedPath) && !chroma.exists(dstNotUsedPath):
+		log.Infof("Downloading patch %s => %s", entry.Path, sys.SlicePath(dstPath, -2, -1))
+		action := &Action{Kind: ActionDownload, Set: distro, Src: entry.Path, Dst: dstPath}
+		err = chroma.perform(action, func() error { return source.Fetch(entry, dstPath) })
+		return
+	}
+
+	// Revalidate the existing patch in its current location
+	if refresh {
+		action := &Action{Kind: ActionDownload, Set: distro, Src: entry.Path, Dst: dstPath}
+		err = chroma.perform(action, func() (err error) {
+			var before, after string
+			if before, err = sys.MD5(dstPath); err != nil {
+				return
+			}
+			if err = source.Fetch(entry, dstPath); err != nil {
+				return
+			}
+			if after, err = sys.MD5(dstPath); err != nil {
+				return
+			}
+			if changed = before != after; changed {
+				log.Infof("Updated patch %s => %s", entry.Path, sys.SlicePath(dstPath, -2, -1))
+			}
 			return
-		}
+		})
 	}
 	return
 }
 
-// Read the order files from disk, downloading if it doesn't exist
-func readOrderFile(distro, patchSetDir string) (order *n.StringSlice, err error) {
-
-	// Read in the patch order file, downloading if needed
-	orderFile := path.Join(patchSetDir, path.Base(gPatchSets[distro]))
-	if !sys.Exists(orderFile) {
-		log.Infof("Downloading patch order file %s", gPatchSets[distro])
-		if _, err = mech.Download(gPatchSets[distro], orderFile); err != nil {
-			return
-		}
+// Rename the given existing patch in place to the given name if its order number changed
+func (chroma *Chroma) renumberPatch(patch *localPatch, patchSetDir, dstName string) (err error) {
+	if patch.Name == dstName || chroma.exists(path.Join(patchSetDir, dstName)) ||
+		chroma.exists(path.Join(patchSetDir, NotUsedDir, dstName)) {
+		return
 	}
-
-	// Read in the order file
-	var data []string
-	if data, err = sys.ReadLines(orderFile); err != nil {
+	dir := patchSetDir
+	if !patch.Used {
+		dir = path.Join(patchSetDir, NotUsedDir)
+	}
+	log.Infof("Renumbering patch %s => %s", patch.Name, dstName)
+	if err = chroma.move(path.Join(dir, patch.Name), path.Join(dir, dstName)); err != nil {
 		return
 	}
-	order = n.S(data)
-
-	// Trim out any empty lines
-	order.DropW(func(x n.O) bool {
-		return n.ExB(x.(string) == "")
-	})
-
+	patch.Name = dstName
 	return
 }
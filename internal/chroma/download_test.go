@@ -0,0 +1,159 @@
+package chroma
+
+import (
+	"path"
+	"testing"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadCmd(t *testing.T) {
+	c := newChroma()
+
+	// Patches default to the default distributions
+	patches := subCommand(c.newDownloadCmd(), "patches")
+	assert.Nil(t, patches.ValidateArgs(nil))
+
+	// Cleaning applies to the sub commands
+	assert.NotNil(t, patches.InheritedFlags().Lookup("clean"))
+	assert.NotNil(t, subCommand(c.newDownloadCmd(), "extensions").InheritedFlags().Lookup("clean"))
+}
+
+func TestDownloadPatchesKeepPaths(t *testing.T) {
+	c, dir := newTestPackage(t, `version: 1
+patchsets:
+  team:
+    source: dir
+    url: team
+    keepPaths: true
+    patches:
+      - name: core/a.patch
+        enabled: true
+`)
+	defer sys.RemoveAll(dir)
+	writeTestFiles(t, path.Join(dir, "team"), map[string]string{
+		"series":        "core/a.patch\nextra/a.patch\n",
+		"core/a.patch":  "core",
+		"extra/a.patch": "extra",
+	})
+
+	// Same base names in different directories don't collide
+	assert.Nil(t, c.downloadPatches([]string{"team"}, &downloadOpts{}))
+	patchSetDir := path.Join(dir, "patches", "team")
+	assert.True(t, sys.Exists(path.Join(patchSetDir, "core/a.patch")))
+	assert.True(t, sys.Exists(path.Join(patchSetDir, "not-used/extra/a.patch")))
+
+	// Sorting moves by upstream path
+	c.manifest.PatchSets["team"].patch("core/a.patch").Enabled = false
+	assert.Nil(t, c.sortPatches("team", c.manifest.PatchSets["team"]))
+	assert.True(t, sys.Exists(path.Join(patchSetDir, "not-used/core/a.patch")))
+}
+
+func TestDownloadPatchesRenumber(t *testing.T) {
+	c, dir := newTestPackage(t, `version: 1
+patchsets:
+  team:
+    source: dir
+    url: team
+    patches:
+      - name: b.patch
+        enabled: true
+`)
+	defer sys.RemoveAll(dir)
+	teamDir := path.Join(dir, "team")
+	writeTestFiles(t, teamDir, map[string]string{
+		"series":  "a.patch\nb.patch\n",
+		"a.patch": "a",
+		"b.patch": "b",
+	})
+	assert.Nil(t, c.downloadPatches([]string{"team"}, &downloadOpts{}))
+	patchSetDir := path.Join(dir, "patches", "team")
+	assert.True(t, sys.Exists(path.Join(patchSetDir, "not-used/00-a.patch")))
+	assert.True(t, sys.Exists(path.Join(patchSetDir, "01-b.patch")))
+
+	// Inserting a patch upstream renames the existing patches rather than downloading duplicates
+	writeTestFiles(t, teamDir, map[string]string{"series": "new.patch\na.patch\nb.patch\n", "new.patch": "new"})
+	assert.Nil(t, sys.WriteString(path.Join(teamDir, "b.patch"), "changed"))
+	assert.Nil(t, c.downloadPatches([]string{"team"}, &downloadOpts{}))
+	patches, err := localPatches(patchSetDir)
+	assert.Nil(t, err)
+	assert.Equal(t, []*localPatch{
+		{Name: "02-b.patch", Used: true},
+		{Name: "00-new.patch", Used: false},
+		{Name: "01-a.patch", Used: false},
+	}, patches)
+	data, err := sys.ReadString(path.Join(patchSetDir, "02-b.patch"))
+	assert.Nil(t, err)
+	assert.Equal(t, "b", data)
+}
+
+func TestDownloadPatchesPrune(t *testing.T) {
+	c, dir := newTestPackage(t, `version: 1
+patchsets:
+  team:
+    source: dir
+    url: team
+    patches:
+      - name: b.patch
+        enabled: true
+`)
+	defer sys.RemoveAll(dir)
+	teamDir := path.Join(dir, "team")
+	writeTestFiles(t, teamDir, map[string]string{
+		"series":  "a.patch\nb.patch\n",
+		"a.patch": "a",
+		"b.patch": "b",
+	})
+	assert.Nil(t, c.downloadPatches([]string{"team"}, &downloadOpts{}))
+	patchSetDir := path.Join(dir, "patches", "team")
+
+	// Dropped patches are only listed without --prune
+	writeTestFiles(t, teamDir, map[string]string{"series": "b.patch\n"})
+	assert.Nil(t, c.downloadPatches([]string{"team"}, &downloadOpts{}))
+	patches, err := c.obsoletePatches(patchSetDir, []string{"b.patch"})
+	assert.Nil(t, err)
+	assert.Equal(t, []*localPatch{{Name: "00-a.patch", Used: false}}, patches)
+
+	// Dry run leaves them in place
+	c.dryrun = true
+	assert.Nil(t, c.pruneSortedPatches("team", &pruneOpts{prune: true}))
+	assert.True(t, sys.Exists(path.Join(patchSetDir, "not-used/00-a.patch")))
+	c.dryrun = false
+
+	// Pruning moves them to the obsolete directory where they're no longer considered
+	assert.Nil(t, c.pruneSortedPatches("team", &pruneOpts{prune: true}))
+	assert.True(t, sys.Exists(path.Join(patchSetDir, "obsolete/00-a.patch")))
+	patches, err = localPatches(patchSetDir)
+	assert.Nil(t, err)
+	assert.Equal(t, []*localPatch{{Name: "00-b.patch", Used: true}}, patches)
+}
+
+func TestDownloadPatchesUnmapped(t *testing.T) {
+	c, dir := newTestPackage(t, `version: 1
+patchsets:
+  team:
+    source: dir
+    url: team
+    patches:
+      - name: a.patch
+        enabled: false
+`)
+	defer sys.RemoveAll(dir)
+	writeTestFiles(t, path.Join(dir, "team"), map[string]string{
+		"series":  "a.patch\nb.patch\n",
+		"a.patch": "a",
+		"b.patch": "b",
+	})
+
+	// Explicitly disabled and unknown patches are both left disabled
+	set := c.manifest.PatchSets["team"]
+	assert.True(t, set.mapped("a.patch"))
+	assert.False(t, set.mapped("b.patch"))
+	assert.Nil(t, c.downloadPatches([]string{"team"}, &downloadOpts{}))
+	assert.True(t, sys.Exists(path.Join(dir, "patches/team/not-used/01-b.patch")))
+
+	// Unknown patches fail the download when asked to
+	err := c.downloadPatches([]string{"team"}, &downloadOpts{failOnUnmapped: true})
+	assert.Equal(t, "Error: 1 patches aren't classified as enabled or disabled", err.Error())
+}
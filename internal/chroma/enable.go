@@ -0,0 +1,177 @@
+package chroma
+
+import (
+	"path"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func (chroma *Chroma) newEnableCmd() *cobra.Command {
+	reason := ""
+	cmd := &cobra.Command{
+		Use:   "enable DISTRO PATCH...",
+		Short: "Enable patches and persist the decision to the manifest",
+		Long: `Enable patches and persist the decision to the manifest. The decision is written to
+the chroma.yaml manifest next to the PKGBUILD and the downloaded patches are sorted to match.
+Patches are named by upstream path, local file name or name without the order number.
+
+Examples:
+	# Enable a debian patch
+	chroma enable debian system/vpx.patch --reason "our libvpx is recent enough"
+
+	# Enable several ungoogled patches
+	chroma enable ungoogled disable-crash-reporter.patch disable-fonts-googleapis-references.patch
+`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			if err = chroma.configure(); err != nil {
+				return
+			}
+			return chroma.setPatches(args[0], args[1:], true, reason)
+		},
+	}
+	cmd.Flags().StringVar(&reason, "reason", "", "Rationale for enabling the patches")
+	return cmd
+}
+
+// setPatches enables or disables the named patches of the given distribution with the given
+// reason, persists the decisions to the manifest and sorts the named downloaded patches to match.
+// Existing decisions only have their state and reason changed and without a reason the existing
+// reason is kept unless the patch's state changes.
+func (chroma *Chroma) setPatches(distro string, names []string, enabled bool, reason string) (err error) {
+	set, ok := chroma.manifest.PatchSets[distro]
+	if !ok {
+		return errors.Errorf("Error: unsupported patch set %s", distro)
+	}
+
+	// Resolve every name before changing anything
+	patches := []*Patch{}
+	for _, name := range names {
+		var resolved string
+		if resolved, err = chroma.resolvePatch(distro, name); err != nil {
+			return
+		}
+		patch := &Patch{Name: resolved}
+		if existing := set.lookup(resolved); existing != nil {
+			*patch = *existing
+			if reason == "" && existing.Enabled != enabled {
+				patch.Reason = ""
+			}
+		}
+		patch.Enabled = enabled
+		if reason != "" {
+			patch.Reason = reason
+		}
+		state := PatchDisabled
+		if enabled {
+			state = PatchEnabled
+		}
+		log.Infof("Marking patch %s/%s as %s", distro, resolved, state)
+		patches = append(patches, patch)
+	}
+	if err = chroma.savePatches(distro, patches...); err != nil {
+		return
+	}
+
+	// Only the named patches are moved leaving the rest as they are
+	resolved := []string{}
+	for _, patch := range patches {
+		resolved = append(resolved, patch.Name)
+	}
+	return chroma.sortPatches(distro, set, resolved...)
+}
+
+// resolvePatch returns the name of the given distribution's patch to record decisions under.
+// Existing decisions are updated under their own name e.g. the base name of the upstream path
+// otherwise the upstream path is used. Unknown names fail with the closest known name.
+func (chroma *Chroma) resolvePatch(distro, name string) (resolved string, err error) {
+	var state *syncState
+	if state, err = loadSyncState(path.Join(chroma.patchesDir, distro)); err != nil {
+		return
+	}
+	var patches []*patchFile
+	if patches, err = chroma.findPatches(path.Join(distro, name)); err != nil {
+		return
+	}
+
+	set := chroma.manifest.PatchSets[distro]
+	switch {
+	case len(patches) > 0:
+		resolved = patches[0].Path
+	case containsString(state.Series, name):
+		resolved = name
+	default:
+		for _, upstream := range state.Series {
+			if path.Base(upstream) == name {
+				resolved = upstream
+				break
+			}
+		}
+	}
+	if decision := set.lookup(name); resolved == "" && decision != nil {
+		resolved = decision.Name
+	}
+	if resolved == "" {
+		candidates := append([]string{}, state.Series...)
+		for _, patch := range set.Patches {
+			candidates = append(candidates, patch.Name)
+		}
+		if suggestion := closestString(name, candidates); suggestion != "" {
+			err = errors.Errorf("unknown patch %s in %s, did you mean %s?", name, distro, suggestion)
+		} else {
+			err = errors.Errorf("unknown patch %s in %s", name, distro)
+		}
+		return
+	}
+	if decision := set.lookup(resolved); decision != nil {
+		resolved = decision.Name
+	}
+	return
+}
+
+// closestString returns the candidate closest to the given value by edit distance or an empty
+// string if none are close enough to be a likely typo. Candidates are also compared by base name.
+func closestString(value string, candidates []string) (closest string) {
+	best := len(value)/3 + 2
+	for _, candidate := range candidates {
+		distance := editDistance(value, candidate)
+		if d := editDistance(path.Base(value), path.Base(candidate)); d < distance {
+			distance = d
+		}
+		if distance < best {
+			best, closest = distance, candidate
+		}
+	}
+	return
+}
+
+// editDistance returns the Levenshtein distance between the given strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// minInt returns the smaller of the given integers
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
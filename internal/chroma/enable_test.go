@@ -0,0 +1,79 @@
+package chroma
+
+import (
+	"path"
+	"testing"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetPatches(t *testing.T) {
+	c, dir := newTestPackage(t, `version: 1
+patchsets:
+  team:
+    patches:
+      - name: a.patch
+        enabled: true
+        source: team
+        reason: needed
+        tags: [privacy]
+        versions: ">=70"
+`)
+	defer sys.RemoveAll(dir)
+	patchSetDir := path.Join(dir, "patches/team")
+	writeTestFiles(t, patchSetDir, map[string]string{
+		".sync.json":            `{"series":["core/a.patch","system/vpx.patch","core/b.patch"]}`,
+		"00-a.patch":            "a",
+		"not-used/01-vpx.patch": "vpx",
+		"02-b.patch":            "b",
+	})
+
+	// Disabling keeps the existing entry's name and source and moves the file
+	assert.Nil(t, c.setPatches("team", []string{"00-a.patch"}, false, "breaks the build"))
+	assert.True(t, sys.Exists(path.Join(patchSetDir, "not-used/00-a.patch")))
+	assert.Equal(t, &Patch{Name: "a.patch", Source: "team", Reason: "breaks the build", Tags: []string{"privacy"}, Versions: ">=70"},
+		c.manifest.PatchSets["team"].patch("a.patch"))
+
+	// Enabling an unmapped patch records its upstream path
+	assert.Nil(t, c.setPatches("team", []string{"vpx.patch"}, true, ""))
+	assert.True(t, sys.Exists(path.Join(patchSetDir, "01-vpx.patch")))
+
+	// Patches that weren't named are left where they are even if the manifest disagrees
+	assert.True(t, sys.Exists(path.Join(patchSetDir, "02-b.patch")))
+
+	// Decisions are persisted to the manifest
+	manifest, err := loadManifest(path.Join(dir, ManifestName))
+	assert.Nil(t, err)
+	assert.Equal(t, []*Patch{
+		{Name: "a.patch", Source: "team", Reason: "breaks the build", Tags: []string{"privacy"}, Versions: ">=70"},
+		{Name: "system/vpx.patch", Enabled: true},
+	}, manifest.PatchSets["team"].Patches)
+
+	// Unknown names are refused with a suggestion
+	err = c.setPatches("team", []string{"system/vp.patch"}, true, "")
+	assert.Equal(t, "unknown patch system/vp.patch in team, did you mean system/vpx.patch?", err.Error())
+	err = c.setPatches("team", []string{"unrelated-name.patch"}, true, "")
+	assert.Equal(t, "unknown patch unrelated-name.patch in team", err.Error())
+}
+
+func TestSetPatchesReload(t *testing.T) {
+	c, dir := newTestPackage(t, "")
+	defer sys.RemoveAll(dir)
+
+	// Disabling a built-in decision keeps everything but its state and reason across reloads
+	assert.Nil(t, c.setPatches("debian", []string{"manpage.patch"}, false, "we ship our own docs"))
+	assert.Nil(t, c.configure())
+	patch := c.manifest.PatchSets["debian"].patch("manpage.patch")
+	assert.False(t, patch.Enabled)
+	assert.Equal(t, "we ship our own docs", patch.Reason)
+	assert.Equal(t, []string{"debian-branding"}, c.manifest.PatchSets["debian"].tags("manpage.patch"))
+
+	// Re-enabling without a reason drops the now stale reason
+	assert.Nil(t, c.setPatches("debian", []string{"manpage.patch"}, true, ""))
+	assert.Nil(t, c.configure())
+	patch = c.manifest.PatchSets["debian"].patch("manpage.patch")
+	assert.True(t, patch.Enabled)
+	assert.Equal(t, "", patch.Reason)
+	assert.Equal(t, []string{"debian-branding"}, patch.Tags)
+}
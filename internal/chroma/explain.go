@@ -0,0 +1,107 @@
+package chroma
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func (chroma *Chroma) newExplainCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "explain PATCH",
+		Short: "Explain which rule or decision classified a downloaded patch",
+		Long: `Explain which rule or decision classified a downloaded patch. Per patch decisions
+win over rules and rules are evaluated by descending priority then the order they're declared
+in. Every rule matching the patch is listed in evaluation order along with the one that
+decided the patch's state. The patch is named as for 'chroma patches info'.
+
+Examples:
+	# Explain why a debian patch is disabled
+	chroma explain debian/system/vpx.patch
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			if err = chroma.configure(); err != nil {
+				return
+			}
+			var explanations []*explanation
+			if explanations, err = chroma.explain(args[0]); err != nil {
+				return
+			}
+			chroma.printExplanations(explanations)
+			return
+		},
+	}
+	return cmd
+}
+
+// explanation is how a downloaded patch was classified
+type explanation struct {
+	Patch    *patchFile // downloaded patch
+	Decision *Patch     // resulting decision or nil if unclassified
+	Rule     *Rule      // rule that made the decision or nil if a per patch decision did
+	Rules    []*Rule    // rules matching the patch in evaluation order
+}
+
+// explain returns how the named downloaded patches were classified
+func (chroma *Chroma) explain(name string) (explanations []*explanation, err error) {
+	var patches []*patchFile
+	if patches, err = chroma.findPatches(name); err != nil {
+		return
+	}
+	if len(patches) == 0 {
+		err = errors.Errorf("no downloaded patch named %s", name)
+		return
+	}
+	for _, patch := range patches {
+		x := &explanation{Patch: patch}
+		if set, ok := chroma.manifest.PatchSets[patch.Distro]; ok && set != nil {
+			x.Decision, x.Rule = set.classify(patch.Path)
+			x.Rules = set.matchingRules(patch.Path)
+		}
+		explanations = append(explanations, x)
+	}
+	return
+}
+
+// Print out the given explanations
+func (chroma *Chroma) printExplanations(explanations []*explanation) {
+	for i, x := range explanations {
+		if i > 0 {
+			chroma.println()
+		}
+		state := PatchUnknown
+		if x.Decision != nil {
+			state = PatchDisabled
+			if x.Decision.Enabled {
+				state = PatchEnabled
+			}
+		}
+		chroma.printf("%-10s %s\n", "Patch:", x.Patch)
+		chroma.printf("%-10s %s\n", "Upstream:", x.Patch.Path)
+		chroma.printf("%-10s %s\n", "State:", state)
+		switch {
+		case x.Decision == nil:
+			chroma.printf("%-10s %s\n", "Decided:", "no decision or rule matches, left disabled")
+		case x.Rule == nil:
+			chroma.printf("%-10s patch %s\n", "Decided:", x.Decision.Name)
+		default:
+			chroma.printf("%-10s rule %s\n", "Decided:", x.Rule)
+		}
+		if x.Decision != nil && x.Decision.Reason != "" {
+			chroma.printf("%-10s %s\n", "Reason:", x.Decision.Reason)
+		}
+		if len(x.Rules) > 0 {
+			chroma.printf("Rules:\n")
+		}
+		for _, rule := range x.Rules {
+			mark, state := " ", PatchDisabled
+			if rule == x.Rule {
+				mark = "*"
+			}
+			if rule.Enabled {
+				state = PatchEnabled
+			}
+			chroma.printf("  %s %-30s priority %-4d %-9s %s\n", mark, rule, rule.Priority, state, rule.Reason)
+		}
+	}
+}
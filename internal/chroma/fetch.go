@@ -0,0 +1,256 @@
+package chroma
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// SyncStateName is the name of the patch set state file tracking the last sync
+	SyncStateName = ".sync.json"
+
+	// DefaultMaxAge is how long a synced patch set is used before it is revalidated upstream
+	DefaultMaxAge = 24 * time.Hour
+)
+
+// syncState tracks what was downloaded for a patch set so it can be revalidated upstream
+type syncState struct {
+	Synced   time.Time             `json:"synced"`             // last time the patch set was revalidated
+	Series   []string              `json:"series"`             // upstream paths of the patches in order
+	Strips   map[string]int        `json:"strips,omitempty"`   // upstream path to strip level if not the default
+	Reversed []string              `json:"reversed,omitempty"` // upstream paths of the patches applied in reverse
+	Files    map[string]*cacheInfo `json:"files,omitempty"`    // url to cache validators
+}
+
+// cacheInfo holds the HTTP cache validators for a downloaded url
+type cacheInfo struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// syncChanges are the upstream differences found between two syncs
+type syncChanges struct {
+	Added   []string // patches added upstream
+	Removed []string // patches removed upstream
+	Changed []string // patches whose content changed upstream
+}
+
+// Load the sync state for the given patch set directory returning an empty state if none exists
+func loadSyncState(patchSetDir string) (state *syncState, err error) {
+	state = &syncState{Files: map[string]*cacheInfo{}}
+	stateFile := path.Join(patchSetDir, SyncStateName)
+	if !sys.Exists(stateFile) {
+		return
+	}
+	var data []byte
+	if data, err = sys.ReadBytes(stateFile); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, state); err != nil {
+		err = errors.Wrapf(err, "failed to parse sync state %s", stateFile)
+		return
+	}
+	if state.Files == nil {
+		state.Files = map[string]*cacheInfo{}
+	}
+	return
+}
+
+// Save the given sync state to the given patch set directory if it has changed
+func (chroma *Chroma) saveSyncState(patchSetDir string, state *syncState) (err error) {
+	var data []byte
+	if data, err = json.MarshalIndent(state, "", "  "); err != nil {
+		err = errors.Wrap(err, "failed to marshal sync state")
+		return
+	}
+	return chroma.update(path.Join(patchSetDir, SyncStateName), data)
+}
+
+// record sets the series of the state to the given entries in order
+func (state *syncState) record(entries []*PatchEntry) {
+	state.Series = []string{}
+	state.Strips = map[string]int{}
+	state.Reversed = nil
+	for _, entry := range entries {
+		state.Series = append(state.Series, entry.Path)
+		if entry.Strip != DefaultStrip {
+			state.Strips[entry.Path] = entry.Strip
+		}
+		if entry.Reverse {
+			state.Reversed = append(state.Reversed, entry.Path)
+		}
+	}
+}
+
+// reversed returns true if the given upstream path is applied in reverse
+func (state *syncState) reversed(name string) bool {
+	return containsString(state.Reversed, name)
+}
+
+// strip returns the strip level for the given upstream path
+func (state *syncState) strip(name string) int {
+	if strip, ok := state.Strips[name]; ok {
+		return strip
+	}
+	return DefaultStrip
+}
+
+// upstream returns the recorded upstream path of the given local patch or an empty string if
+// the patch isn't in the recorded series. Flattened patches are matched by identity.
+func (state *syncState) upstream(name string) string {
+	for _, upstream := range state.Series {
+		if upstream == name || (path.Dir(name) == "." && path.Base(upstream) == patchID(name)) {
+			return upstream
+		}
+	}
+	return ""
+}
+
+// stale returns true if the state hasn't been revalidated within the given max age
+func (state *syncState) stale(maxAge time.Duration) bool {
+	return maxAge > 0 && time.Since(state.Synced) > maxAge
+}
+
+// diff returns the patches added and removed upstream between the state's series and the given entries
+func (state *syncState) diff(entries []*PatchEntry) (changes *syncChanges) {
+	changes = &syncChanges{}
+	previous := map[string]bool{}
+	for _, name := range state.Series {
+		previous[name] = true
+	}
+	current := map[string]bool{}
+	for _, entry := range entries {
+		current[entry.Path] = true
+		if !previous[entry.Path] {
+			changes.Added = append(changes.Added, entry.Path)
+		}
+	}
+	for _, name := range state.Series {
+		if !current[name] {
+			changes.Removed = append(changes.Removed, name)
+		}
+	}
+	return
+}
+
+// empty returns true if there were no changes
+func (changes *syncChanges) empty() bool {
+	return len(changes.Added) == 0 && len(changes.Removed) == 0 && len(changes.Changed) == 0
+}
+
+// fetcher downloads urls to disk recording cache validators in the sync state so that
+// previously downloaded files can be revalidated with conditional requests.
+type fetcher struct {
+	client     *http.Client // client to make requests with
+	state      *syncState   // state to record cache validators in
+	revalidate bool         // true to revalidate files that already exist
+
+	// cache writes cache files e.g. order files, defaults to writing them directly
+	cache func(dst string, data []byte) error
+}
+
+func newFetcher(state *syncState, revalidate bool) *fetcher {
+	return &fetcher{client: &http.Client{}, state: state, revalidate: revalidate}
+}
+
+// fetch downloads the given url to the given destination if it doesn't exist or if it has
+// changed upstream when revalidating. Returns true if the destination was written.
+func (fetcher *fetcher) fetch(uri, dst string) (written bool, err error) {
+	return fetcher.get(uri, dst, func(tmp string) (err error) {
+		if err = os.Rename(tmp, dst); err != nil {
+			err = errors.Wrapf(err, "failed to move download into place %s", dst)
+		}
+		return
+	})
+}
+
+// fetchCache downloads the given url to the given cache file e.g. an order file like fetch
+// but writes it out with the cache writer.
+func (fetcher *fetcher) fetchCache(uri, dst string) (written bool, err error) {
+	return fetcher.get(uri, dst, func(tmp string) (err error) {
+		var data []byte
+		if data, err = sys.ReadBytes(tmp); err != nil {
+			return
+		}
+		os.Remove(tmp)
+		return fetcher.save(dst, data)
+	})
+}
+
+// save writes the given data to the given cache file using the cache writer if set
+func (fetcher *fetcher) save(dst string, data []byte) (err error) {
+	if fetcher.cache != nil {
+		return fetcher.cache(dst, data)
+	}
+	if _, err = sys.MkdirP(path.Dir(dst)); err != nil {
+		return
+	}
+	return sys.WriteBytes(dst, data)
+}
+
+// get downloads the given url to a temporary file beside the given destination then calls
+// the given function to put it into place.
+func (fetcher *fetcher) get(uri, dst string, place func(tmp string) error) (written bool, err error) {
+	exists := sys.Exists(dst)
+	if exists && !fetcher.revalidate {
+		return
+	}
+
+	var req *http.Request
+	if req, err = http.NewRequest("GET", uri, nil); err != nil {
+		err = errors.Wrapf(err, "failed to create request for %s", uri)
+		return
+	}
+	req.Header.Set("User-Agent", fmt.Sprintf("chroma/%s", VERSION))
+	info := fetcher.state.Files[uri]
+	if exists && info != nil {
+		if info.ETag != "" {
+			req.Header.Set("If-None-Match", info.ETag)
+		}
+		if info.LastModified != "" {
+			req.Header.Set("If-Modified-Since", info.LastModified)
+		}
+	}
+
+	var res *http.Response
+	if res, err = fetcher.client.Do(req); err != nil {
+		err = errors.Wrapf(err, "failed to GET url %s", uri)
+		return
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotModified:
+		log.Debugf("Not modified %s", uri)
+		return
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		err = errors.Errorf("failed to GET url %s: %s", uri, res.Status)
+		return
+	}
+
+	// Write to a temporary file first so an interrupted download never leaves a partial file
+	if _, err = sys.MkdirP(path.Dir(dst)); err != nil {
+		return
+	}
+	tmp := dst + ".tmp"
+	if err = sys.WriteStream(res.Body, tmp); err != nil {
+		os.Remove(tmp)
+		err = errors.Wrapf(err, "failed to download url %s", uri)
+		return
+	}
+	if err = place(tmp); err != nil {
+		os.Remove(tmp)
+		return
+	}
+	fetcher.state.Files[uri] = &cacheInfo{ETag: res.Header.Get("ETag"), LastModified: res.Header.Get("Last-Modified")}
+	written = true
+	return
+}
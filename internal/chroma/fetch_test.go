@@ -0,0 +1,116 @@
+package chroma
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chroma")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	body := "series v1"
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		etag := `"` + body + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	// First download records the validators
+	state := &syncState{Files: map[string]*cacheInfo{}}
+	dst := path.Join(dir, "debian", "series")
+	written, err := newFetcher(state, false).fetch(server.URL, dst)
+	assert.Nil(t, err)
+	assert.True(t, written)
+	assert.Equal(t, `"series v1"`, state.Files[server.URL].ETag)
+
+	// Existing files aren't requested again unless revalidating
+	written, err = newFetcher(state, false).fetch(server.URL, dst)
+	assert.Nil(t, err)
+	assert.False(t, written)
+	assert.Equal(t, 1, requests)
+
+	// Unchanged upstream is left alone
+	written, err = newFetcher(state, true).fetch(server.URL, dst)
+	assert.Nil(t, err)
+	assert.False(t, written)
+	assert.Equal(t, 2, requests)
+
+	// Changed upstream is downloaded again
+	body = "series v2"
+	written, err = newFetcher(state, true).fetch(server.URL, dst)
+	assert.Nil(t, err)
+	assert.True(t, written)
+	data, _ := sys.ReadString(dst)
+	assert.Equal(t, "series v2", data)
+
+	// Errors aren't written to disk
+	server.Config.Handler = http.NotFoundHandler()
+	_, err = newFetcher(state, false).fetch(server.URL+"/bogus", path.Join(dir, "bogus"))
+	assert.NotNil(t, err)
+	assert.False(t, sys.Exists(path.Join(dir, "bogus")))
+}
+
+func TestSyncState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chroma")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Missing state is empty and stale
+	state, err := loadSyncState(dir)
+	assert.Nil(t, err)
+	assert.True(t, state.stale(DefaultMaxAge))
+	assert.False(t, state.stale(0))
+
+	// Round trip
+	state.Synced = time.Now()
+	state.Series = []string{"a.patch", "b.patch"}
+	c := &Chroma{rootDir: dir}
+	assert.Nil(t, c.saveSyncState(dir, state))
+	state, err = loadSyncState(dir)
+	assert.Nil(t, err)
+	assert.False(t, state.stale(DefaultMaxAge))
+
+	changes := state.diff([]*PatchEntry{{Path: "b.patch"}, {Path: "c.patch"}})
+	assert.Equal(t, []string{"c.patch"}, changes.Added)
+	assert.Equal(t, []string{"a.patch"}, changes.Removed)
+	assert.False(t, changes.empty())
+}
+
+func TestFetcherTruncated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chroma")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// The server promises more than it sends before dropping the connection
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte("partial"))
+	}))
+	defer server.Close()
+
+	dst := path.Join(dir, "a.patch")
+	state := &syncState{Files: map[string]*cacheInfo{}}
+	written, err := newFetcher(state, false).fetch(server.URL+"/a.patch", dst)
+	assert.NotNil(t, err)
+	assert.False(t, written)
+	assert.False(t, sys.Exists(dst))
+	assert.False(t, sys.Exists(dst+".tmp"))
+	assert.Nil(t, state.Files[server.URL+"/a.patch"])
+}
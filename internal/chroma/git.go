@@ -0,0 +1,54 @@
+package chroma
+
+import (
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// gitSource provides the patches in a git repository by cloning it into the patch set
+// directory and treating the clone as a local directory source.
+type gitSource struct {
+	*dirSource
+}
+
+func newGitSource(opts *PatchSourceOpts) (source PatchSource, err error) {
+	set := opts.Set
+
+	// Clone the repository if needed or update the clone when refreshing
+	cloneDir := path.Join(opts.PatchSetDir, ".source")
+	if !sys.Exists(cloneDir) {
+		log.Infof("Cloning patch repository %s => %s", set.URL, sys.SlicePath(cloneDir, -3, -1))
+		if err = git("clone", "--depth", "1", set.URL, cloneDir); err != nil {
+			return
+		}
+	} else if opts.Refresh {
+		log.Infof("Updating patch repository %s => %s", set.URL, sys.SlicePath(cloneDir, -3, -1))
+		if err = git("-C", cloneDir, "fetch", "--depth", "1", "origin"); err != nil {
+			return
+		}
+		if err = git("-C", cloneDir, "reset", "--hard", "FETCH_HEAD"); err != nil {
+			return
+		}
+	}
+
+	var dir PatchSource
+	if dir, err = newDirSource(&PatchSourceOpts{Set: &PatchSet{URL: cloneDir, Path: set.Path}}); err != nil {
+		return
+	}
+	source = &gitSource{dir.(*dirSource)}
+	return
+}
+
+// Execute git with the given arguments including its output in any error
+func git(args ...string) (err error) {
+	var out []byte
+	if out, err = exec.Command("git", args...).CombinedOutput(); err != nil {
+		err = errors.Wrapf(err, "failed to execute git %s: %s", strings.Join(args, " "), strings.TrimSpace(string(out)))
+	}
+	return
+}
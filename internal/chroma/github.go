@@ -0,0 +1,106 @@
+package chroma
+
+import (
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/phR0ze/n/pkg/net"
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// githubSource provides the patches discovered at the root of a GitHub repository
+type githubSource struct {
+	uri         string   // url of the GitHub repository
+	patchSetDir string   // local patch set directory to cache the discovered order in
+	fetcher     *fetcher // fetcher to download with
+}
+
+func newGithubSource(opts *PatchSourceOpts) (PatchSource, error) {
+	return &githubSource{uri: opts.Set.URL, patchSetDir: opts.PatchSetDir, fetcher: opts.fetcher}, nil
+}
+
+// Entries returns the patches discovered in the repository sorted by name
+func (source *githubSource) Entries() (entries []*PatchEntry, err error) {
+	return readRepoOrderFile(source.fetcher, source.uri, source.patchSetDir)
+}
+
+// Fetch downloads the given patch from the repository's master branch
+func (source *githubSource) Fetch(entry *PatchEntry, dst string) (err error) {
+	_, err = source.fetcher.fetch(net.JoinURL(githubRawURL(source.uri), entry.Path), dst)
+	return
+}
+
+// Read the order file generated from the patches discovered in the given GitHub repository.
+// GitHub patch repositories don't have a series file so one is generated from the patch
+// files found at the root of the repository sorted by name. The repository listing is
+// cached alongside and revalidated with the rest of the patch set.
+func readRepoOrderFile(fetcher *fetcher, uri, patchSetDir string) (entries []*PatchEntry, err error) {
+
+	// Discover the patches and write out the order file if needed
+	orderFile := path.Join(patchSetDir, "series")
+	listingFile := path.Join(patchSetDir, ".contents.json")
+	var written bool
+	if written, err = fetcher.fetchCache(githubAPIURL(uri), listingFile); err != nil {
+		return
+	}
+	if written || !sys.Exists(orderFile) {
+		log.Infof("Discovering patches in repository %s", uri)
+		var patches []string
+		if patches, err = githubPatches(uri, listingFile); err != nil {
+			return
+		}
+		if err = fetcher.save(orderFile, []byte(strings.Join(patches, "\n"))); err != nil {
+			return
+		}
+	}
+	return readSeriesFile(orderFile)
+}
+
+// List the patch files at the root of the given GitHub repository sorted by name
+// using the given repository contents listing.
+func githubPatches(uri, listingFile string) (patches []string, err error) {
+	var data []byte
+	if data, err = sys.ReadBytes(listingFile); err != nil {
+		return
+	}
+
+	// Decode the GitHub contents listing
+	contents := []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}{}
+	if err = json.Unmarshal(data, &contents); err != nil {
+		err = errors.Wrapf(err, "failed to decode repository contents for %s", uri)
+		return
+	}
+	for _, content := range contents {
+		if content.Type == "file" && path.Ext(content.Name) == ".patch" {
+			patches = append(patches, content.Name)
+		}
+	}
+	if len(patches) == 0 {
+		err = errors.Errorf("no patches found in repository %s", uri)
+		return
+	}
+	sort.Strings(patches)
+	return
+}
+
+// Convert the given GitHub repository url into the contents API url
+func githubAPIURL(uri string) string {
+	return net.JoinURL("https://api.github.com/repos", githubRepo(uri), "contents")
+}
+
+// Convert the given GitHub repository url into the raw content url for master
+func githubRawURL(uri string) string {
+	return net.JoinURL("https://raw.githubusercontent.com", githubRepo(uri), "master")
+}
+
+// Extract the owner/repo portion of the given GitHub repository url
+func githubRepo(uri string) string {
+	return strings.Trim(strings.TrimPrefix(sys.TrimProtocol(uri), "github.com"), "/")
+}
@@ -0,0 +1,14 @@
+package chroma
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGithubURLs(t *testing.T) {
+	uri := gPatchSets["inox"].URL
+	assert.Equal(t, "gcarq/inox-patchset", githubRepo(uri))
+	assert.Equal(t, "https://api.github.com/repos/gcarq/inox-patchset/contents", githubAPIURL(uri))
+	assert.Equal(t, "https://raw.githubusercontent.com/gcarq/inox-patchset/master", githubRawURL(uri))
+}
@@ -0,0 +1,184 @@
+package chroma
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	gRXHeaderField  = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9-]*):[ \t]*(.*)$`)
+	gRXSubjectPatch = regexp.MustCompile(`^\[PATCH[^\]]*\]`)
+
+	// DEP-3 and mail header fields recognized after free form text
+	gHeaderFields = map[string]bool{
+		"description": true, "subject": true, "author": true, "from": true, "origin": true,
+		"bug": true, "forwarded": true, "reviewed-by": true, "acked-by": true, "last-update": true,
+		"applied-upstream": true, "date": true, "signed-off-by": true,
+	}
+)
+
+// PatchHeader is the metadata at the top of a patch file before the diff. Debian patches use
+// DEP-3 fields, git format-patch output uses mail fields and the rest is free form text.
+type PatchHeader struct {
+	Fields []*HeaderField // fields in order
+	Text   []string       // free form text lines
+}
+
+// HeaderField is a single patch header field
+type HeaderField struct {
+	Name  string // field name e.g. Description
+	Value string // field value with continuation lines joined by newlines
+}
+
+// Field returns the value of the first field with the given case insensitive name
+func (header *PatchHeader) Field(name string) string {
+	for _, field := range header.Fields {
+		if strings.EqualFold(field.Name, name) {
+			return field.Value
+		}
+	}
+	return ""
+}
+
+// readPatchHeader parses the header of the given patch file
+func readPatchHeader(filepath string) (header *PatchHeader, err error) {
+	var reader *os.File
+	if reader, err = os.Open(filepath); err != nil {
+		err = errors.Wrapf(err, "failed to open patch %s", filepath)
+		return
+	}
+	defer reader.Close()
+	return parsePatchHeader(reader)
+}
+
+// parsePatchHeader parses the patch header from the given reader stopping at the diff or the
+// diffstat separator of git format-patch output.
+func parsePatchHeader(reader io.Reader) (header *PatchHeader, err error) {
+	header = &PatchHeader{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var field *HeaderField
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "---" || strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "diff ") ||
+			strings.HasPrefix(line, "Index: ") || strings.HasPrefix(line, "@@ ") {
+			break
+		}
+
+		// Skip the mbox separator line of git format-patch output
+		if first && strings.HasPrefix(line, "From ") {
+			first = false
+			continue
+		}
+		first = false
+
+		switch match := gRXHeaderField.FindStringSubmatch(line); {
+
+		// Continuation of the previous field e.g. a DEP-3 long description
+		case field != nil && line != "" && (line[0] == ' ' || line[0] == '\t'):
+			value := strings.TrimSpace(line)
+			if value == "." {
+				value = ""
+			}
+			field.Value += "\n" + value
+
+		// Fields are accepted until free form text starts after which only known fields are
+		case match != nil && (len(header.Text) == 0 || gHeaderFields[strings.ToLower(match[1])]):
+			value := match[2]
+			if strings.EqualFold(match[1], "subject") {
+				value = strings.TrimSpace(gRXSubjectPatch.ReplaceAllString(value, ""))
+			}
+			field = &HeaderField{Name: match[1], Value: value}
+			header.Fields = append(header.Fields, field)
+
+		default:
+			field = nil
+			if line != "" || len(header.Text) > 0 {
+				header.Text = append(header.Text, line)
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		err = errors.Wrap(err, "failed to read patch header")
+		return
+	}
+
+	// Drop trailing blank lines of the free form text
+	for len(header.Text) > 0 && strings.TrimSpace(header.Text[len(header.Text)-1]) == "" {
+		header.Text = header.Text[:len(header.Text)-1]
+	}
+	return
+}
+
+// DiffStat is the number of lines a patch changes per file
+type DiffStat struct {
+	Files []*FileStat // files in patch order
+}
+
+// FileStat is the number of lines a patch changes in a single file
+type FileStat struct {
+	Name       string // file name with the patch's strip level applied
+	Insertions int    // lines added
+	Deletions  int    // lines removed
+	Binary     bool   // binary changes
+}
+
+// newDiffStat counts the changed lines of the given file diffs
+func newDiffStat(diffs []*FileDiff, strip int) (stat *DiffStat) {
+	stat = &DiffStat{}
+	for _, diff := range diffs {
+		file := &FileStat{Name: diff.Name(strip), Binary: diff.Binary}
+		for _, hunk := range diff.Hunks {
+			for _, line := range hunk.Lines {
+				switch line[0] {
+				case '+':
+					file.Insertions++
+				case '-':
+					file.Deletions++
+				}
+			}
+		}
+		stat.Files = append(stat.Files, file)
+	}
+	return
+}
+
+// String returns the diffstat formatted like git's
+func (stat *DiffStat) String() string {
+	width := 0
+	insertions, deletions := 0, 0
+	for _, file := range stat.Files {
+		if len(file.Name) > width {
+			width = len(file.Name)
+		}
+		insertions += file.Insertions
+		deletions += file.Deletions
+	}
+	var b strings.Builder
+	for _, file := range stat.Files {
+		if file.Binary {
+			fmt.Fprintf(&b, " %-*s | Bin\n", width, file.Name)
+			continue
+		}
+		fmt.Fprintf(&b, " %-*s | %d %s%s\n", width, file.Name, file.Insertions+file.Deletions,
+			strings.Repeat("+", file.Insertions), strings.Repeat("-", file.Deletions))
+	}
+	fmt.Fprintf(&b, " %d %s changed, %d %s(+), %d %s(-)\n", len(stat.Files), plural(len(stat.Files), "file", "files"),
+		insertions, plural(insertions, "insertion", "insertions"), deletions, plural(deletions, "deletion", "deletions"))
+	return b.String()
+}
+
+// plural returns the singular or plural form for the given count
+func plural(count int, singular, plural string) string {
+	if count == 1 {
+		return singular
+	}
+	return plural
+}
@@ -0,0 +1,89 @@
+package chroma
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePatchHeader(t *testing.T) {
+
+	// DEP-3 with a long description and free form text
+	header, err := parsePatchHeader(strings.NewReader(`Description: use system libvpx
+ Chromium bundles its own copy.
+ .
+ Link against the system one instead.
+Author: Jane Doe <jane@example.com>
+Bug: https://bugs.debian.org/1
+Last-Update: 2019-08-01
+
+Some notes about the patch.
+
+--- a/media/BUILD.gn
++++ b/media/BUILD.gn
+@@ -1 +1 @@
+-a
++b
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, "use system libvpx\nChromium bundles its own copy.\n\nLink against the system one instead.", header.Field("description"))
+	assert.Equal(t, "Jane Doe <jane@example.com>", header.Field("Author"))
+	assert.Equal(t, "2019-08-01", header.Field("Last-Update"))
+	assert.Equal(t, "", header.Field("Origin"))
+	assert.Equal(t, []string{"Some notes about the patch."}, header.Text)
+
+	// git format-patch output stops at the diffstat
+	header, err = parsePatchHeader(strings.NewReader(`From 1234abcd Mon Sep 17 00:00:00 2001
+From: John Doe <john@example.com>
+Date: Mon, 5 Aug 2019 10:00:00 +0200
+Subject: [PATCH 1/2] Disable the sign in prompt
+
+Body text.
+---
+ chrome/foo.cc | 2 +-
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"From", "Date", "Subject"}, []string{header.Fields[0].Name, header.Fields[1].Name, header.Fields[2].Name})
+	assert.Equal(t, "Disable the sign in prompt", header.Field("Subject"))
+	assert.Equal(t, []string{"Body text."}, header.Text)
+}
+
+func TestPatchInfo(t *testing.T) {
+	c, dir := newTestPackage(t, `version: 1
+patchsets:
+  debian:
+    patches:
+      - name: system/vpx.patch
+        enabled: false
+        source: debian
+        reason: our libvpx is too old
+`)
+	defer sys.RemoveAll(dir)
+	writeTestFiles(t, path.Join(dir, "patches"), map[string]string{
+		"debian/.sync.json":            `{"series":["system/vpx.patch"]}`,
+		"debian/not-used/00-vpx.patch": "Description: use system libvpx\n--- a/media/BUILD.gn\n+++ b/media/BUILD.gn\n@@ -1,2 +1,2 @@\n-a\n+b\n c\n",
+	})
+
+	patches, err := c.findPatches("debian/system/vpx.patch")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(patches))
+	assert.Equal(t, "system/vpx.patch", patches[0].Path)
+	assert.False(t, patches[0].Used)
+	patches, err = c.findPatches("00-vpx.patch")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(patches))
+	out := captureStdout(t, func() { err = c.patchInfo("vpx.patch") })
+	assert.Nil(t, err)
+	assert.Contains(t, out, "State:       disabled\n")
+	assert.Contains(t, out, "Source:      debian\n")
+	assert.Contains(t, out, "Reason:      our libvpx is too old\n")
+	assert.Contains(t, out, "Description: use system libvpx\n")
+	assert.Equal(t, "no downloaded patch named foo.patch", c.patchInfo("foo.patch").Error())
+
+	// Diffstat
+	stat := newDiffStat([]*FileDiff{{NewName: "b/media/BUILD.gn", Hunks: []*Hunk{{Lines: []string{"-a", "+b", " c"}}}}}, 1)
+	assert.Equal(t, " media/BUILD.gn | 2 +-\n 1 file changed, 1 insertion(+), 1 deletion(-)\n", stat.String())
+}
@@ -0,0 +1,148 @@
+package chroma
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/pkg/errors"
+)
+
+const (
+	// JournalDir is the package root sub directory the journal and trash are kept in
+	JournalDir = ".chroma"
+
+	// JournalName is the name of the journal file in the journal directory
+	JournalName = "journal.json"
+
+	// JournalMaxRuns is the number of runs kept in the journal that can be undone
+	JournalMaxRuns = 10
+)
+
+// journal records the changes of each run so that they can be undone
+type journal struct {
+	Runs []*journalRun `json:"runs"` // runs oldest first
+
+	dir     string        // directory the journal and trash are kept in
+	run     *journalRun   // run being recorded
+	current *journalEntry // entry being recorded
+	kept    int           // number of paths kept in the trash by the run
+}
+
+// journalRun is the changes made by a single chroma command
+type journalRun struct {
+	ID      string          `json:"id"`      // unique id of the run also naming its trash directory
+	Command string          `json:"command"` // command that made the changes
+	Entries []*journalEntry `json:"entries"` // changes made in order
+}
+
+// journalEntry is a single change and what is needed to revert it
+type journalEntry struct {
+	Action  *Action `json:"action"`            // action performed
+	Created string  `json:"created,omitempty"` // top most directory created by the action
+	Trash   string  `json:"trash,omitempty"`   // where the removed or overwritten path was kept
+}
+
+// Load the journal from the given package root returning an empty journal if none exists
+func loadJournal(rootDir string) (j *journal, err error) {
+	j = &journal{dir: path.Join(rootDir, JournalDir)}
+	filepath := path.Join(j.dir, JournalName)
+	if !sys.Exists(filepath) {
+		return
+	}
+	var data []byte
+	if data, err = sys.ReadBytes(filepath); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, j); err != nil {
+		err = errors.Wrapf(err, "failed to parse journal %s", filepath)
+		return
+	}
+	return
+}
+
+// Save the journal dropping the trash of runs too old to be undone
+func (j *journal) save() (err error) {
+	for len(j.Runs) > JournalMaxRuns {
+		sys.RemoveAll(j.trashDir(j.Runs[0]))
+		j.Runs = j.Runs[1:]
+	}
+	if _, err = sys.MkdirP(j.dir); err != nil {
+		return
+	}
+	var data []byte
+	if data, err = json.MarshalIndent(j, "", "  "); err != nil {
+		err = errors.Wrap(err, "failed to marshal journal")
+		return
+	}
+	return sys.WriteBytes(path.Join(j.dir, JournalName), data)
+}
+
+// trashDir returns the directory the given run's removed files are kept in
+func (j *journal) trashDir(run *journalRun) string {
+	return path.Join(j.dir, "trash", run.ID)
+}
+
+// Record the given action performed by the given function as part of the run for the given
+// command. Files the action overwrites are kept in the trash first so they can be restored.
+func (j *journal) record(command string, action *Action, fn func() error) (err error) {
+	if j.run == nil {
+		j.run = &journalRun{ID: time.Now().Format("20060102T150405.000000000"), Command: command}
+		j.Runs = append(j.Runs, j.run)
+	}
+	// Actions may be performed while recording another e.g. by a patch source so the entry
+	// being recorded is restored afterwards
+	entry, previous := &journalEntry{Action: action}, j.current
+	j.current = entry
+	defer func() { j.current = previous }()
+
+	switch action.Kind {
+	case ActionMkdir:
+		for dir := action.Dst; !sys.Exists(dir); dir = path.Dir(dir) {
+			entry.Created = dir
+		}
+	case ActionDownload, ActionGenerate, ActionLink, ActionWrite:
+		if sys.Exists(action.Dst) {
+			if err = j.keep(action.Dst, true); err != nil {
+				return
+			}
+		}
+	}
+
+	// Record the entry even on failure as it may have partially changed the disk
+	err = fn()
+	j.run.Entries = append(j.run.Entries, entry)
+	if e := j.save(); e != nil && err == nil {
+		err = e
+	}
+	return
+}
+
+// Move the given path to the trash recording it against the entry being recorded
+func (j *journal) trash(target string) (err error) {
+	return j.keep(target, false)
+}
+
+// Keep the given path in the trash by moving or copying it there
+func (j *journal) keep(target string, copy bool) (err error) {
+	if j.current == nil {
+		return errors.Errorf("no journal entry being recorded for %s", target)
+	}
+	j.kept++
+	dst := path.Join(j.trashDir(j.run), fmt.Sprintf("%03d-%s", j.kept, path.Base(target)))
+	if _, err = sys.MkdirP(path.Dir(dst)); err != nil {
+		return
+	}
+	if copy {
+		_, err = sys.CopyFile(target, dst)
+	} else if err = os.Rename(target, dst); err != nil {
+		err = errors.Wrapf(err, "failed to move %s to the trash", target)
+	}
+	if err == nil {
+		j.current.Trash = dst
+	}
+	return
+}
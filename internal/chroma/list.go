@@ -0,0 +1,200 @@
+package chroma
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// PatchEnabled is the state of a patch classified as enabled
+	PatchEnabled = "enabled"
+
+	// PatchDisabled is the state of a patch classified as disabled
+	PatchDisabled = "disabled"
+
+	// PatchUnknown is the state of a patch that isn't classified
+	PatchUnknown = "unknown"
+)
+
+var (
+	gListFormats = []string{"table", "json", "csv"}
+)
+
+type listOpts struct {
+	format   string   // output format
+	enabled  bool     // only list enabled patches
+	disabled bool     // only list disabled patches
+	missing  bool     // only list patches without a local file
+	tags     []string // only list patches with any of these tags
+}
+
+func (chroma *Chroma) newListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List the state of chromium patches",
+		Aliases: []string{"ls"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	cmd.AddCommand(
+		func() *cobra.Command {
+			opts := &listOpts{}
+			cmd := &cobra.Command{
+				Use:   "patches [DISTROS]",
+				Short: "List the series entries of the downloaded patch sets",
+				Long: `List the series entries of the downloaded patch sets. Each entry of the series
+recorded at the last download is listed with its order, upstream path, local file, whether
+it's classified as enabled, disabled or unknown, whether the local file is present and the
+rationale for the decision. Without distributions every downloaded distribution is listed.
+
+Examples:
+	# List the patches of every downloaded distribution
+	chroma list patches
+
+	# List the disabled debian patches as CSV
+	chroma list patches debian --disabled --format csv
+
+	# List the series entries that haven't been downloaded
+	chroma list patches --missing
+
+	# List the privacy patches
+	chroma list patches --tag privacy
+`,
+				Aliases: []string{"pa", "patch"},
+				RunE: func(cmd *cobra.Command, args []string) (err error) {
+					if err = chroma.configure(); err != nil {
+						return
+					}
+					var rows []*patchRow
+					if rows, err = chroma.listPatches(args, opts); err != nil {
+						return
+					}
+					var out string
+					if out, err = formatPatchRows(rows, opts.format); err != nil {
+						return
+					}
+					chroma.printf("%s", out)
+					return
+				},
+			}
+			cmd.Flags().StringVarP(&opts.format, "format", "f", "table", fmt.Sprintf("Output format %v", gListFormats))
+			cmd.Flags().BoolVar(&opts.enabled, "enabled", false, "Only list enabled patches")
+			cmd.Flags().BoolVar(&opts.disabled, "disabled", false, "Only list disabled patches")
+			cmd.Flags().BoolVar(&opts.missing, "missing", false, "Only list patches without a local file")
+			cmd.Flags().StringSliceVar(&opts.tags, "tag", nil, "Only list patches with any of these tags")
+			return cmd
+		}(),
+	)
+	return cmd
+}
+
+// patchRow is a single series entry of a patch set and its state
+type patchRow struct {
+	Distro  string   `json:"distro"`           // distribution of the patch
+	Order   int      `json:"order"`            // position in the distribution's series
+	Path    string   `json:"path"`             // upstream path of the patch
+	File    string   `json:"file,omitempty"`   // local file relative to the patch set directory
+	State   string   `json:"state"`            // enabled, disabled or unknown
+	Present bool     `json:"present"`          // true if the local file exists
+	Source  string   `json:"source,omitempty"` // project the patch originated from
+	Reason  string   `json:"reason,omitempty"` // rationale for the state
+	Tags    []string `json:"tags,omitempty"`   // categories of the patch
+}
+
+// listPatches returns the series entries of the given distributions filtered by the given options
+func (chroma *Chroma) listPatches(distros []string, opts *listOpts) (rows []*patchRow, err error) {
+	rows = []*patchRow{}
+	for _, distro := range chroma.patchDistros(distros) {
+		patchSetDir := path.Join(chroma.patchesDir, distro)
+		var state *syncState
+		if state, err = loadSyncState(patchSetDir); err != nil {
+			return
+		}
+		var local []*localPatch
+		if local, err = chroma.localPatches(patchSetDir); err != nil {
+			return
+		}
+		files := map[string]*localPatch{}
+		for _, patch := range local {
+			if upstream := state.upstream(patch.Name); upstream != "" {
+				files[upstream] = patch
+			}
+		}
+
+		set := chroma.manifest.PatchSets[distro]
+		for i, upstream := range state.Series {
+			row := &patchRow{Distro: distro, Order: i, Path: upstream, State: PatchUnknown}
+			if patch, ok := files[upstream]; ok {
+				row.File, row.Present = patchPath(patch), true
+			}
+			if set != nil {
+				row.Tags = set.tags(upstream)
+				if decision, _ := set.classify(upstream); decision != nil {
+					row.State, row.Source, row.Reason = PatchDisabled, decision.Source, decision.Reason
+					if decision.Enabled {
+						row.State = PatchEnabled
+					}
+				}
+			}
+			if (opts.enabled && row.State != PatchEnabled) || (opts.disabled && row.State != PatchDisabled) ||
+				(opts.missing && row.Present) || (len(opts.tags) > 0 && !anyString(row.Tags, opts.tags)) {
+				continue
+			}
+			rows = append(rows, row)
+		}
+	}
+	return
+}
+
+// formatPatchRows renders the given rows in the given output format
+func formatPatchRows(rows []*patchRow, format string) (out string, err error) {
+	var buf bytes.Buffer
+	switch format {
+	case "table":
+		w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DISTRO\tORDER\tPATH\tFILE\tSTATE\tPRESENT\tTAGS\tREASON")
+		for _, row := range rows {
+			present := "no"
+			if row.Present {
+				present = "yes"
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n", row.Distro, row.Order, row.Path, row.File, row.State, present,
+				strings.Join(row.Tags, ","), row.Reason)
+		}
+		w.Flush()
+	case "json":
+		var data []byte
+		if data, err = json.MarshalIndent(rows, "", "  "); err != nil {
+			err = errors.Wrap(err, "failed to marshal patch list")
+			return
+		}
+		buf.Write(append(data, '\n'))
+	case "csv":
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"distro", "order", "path", "file", "state", "present", "source", "reason", "tags"})
+		for _, row := range rows {
+			w.Write([]string{row.Distro, strconv.Itoa(row.Order), row.Path, row.File, row.State,
+				strconv.FormatBool(row.Present), row.Source, row.Reason, strings.Join(row.Tags, ";")})
+		}
+		w.Flush()
+		if err = w.Error(); err != nil {
+			err = errors.Wrap(err, "failed to write patch list")
+			return
+		}
+	default:
+		err = errors.Errorf("invalid output format %s, expected one of %v", format, gListFormats)
+		return
+	}
+	out = buf.String()
+	return
+}
@@ -0,0 +1,61 @@
+package chroma
+
+import (
+	"path"
+	"testing"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListPatches(t *testing.T) {
+	c, dir := newTestPackage(t, `version: 1
+patchsets:
+  team:
+    patches:
+      - name: core/a.patch
+        enabled: true
+        tags: [privacy]
+      - name: b.patch
+        enabled: false
+        reason: breaks the build
+`)
+	defer sys.RemoveAll(dir)
+	writeTestFiles(t, path.Join(dir, "patches/team"), map[string]string{
+		".sync.json":          `{"series":["core/a.patch","b.patch","c.patch"]}`,
+		"00-a.patch":          "a",
+		"not-used/01-b.patch": "b",
+	})
+
+	rows, err := c.listPatches([]string{"team"}, &listOpts{})
+	assert.Nil(t, err)
+	assert.Equal(t, []*patchRow{
+		{Distro: "team", Order: 0, Path: "core/a.patch", File: "00-a.patch", State: PatchEnabled, Present: true, Tags: []string{"privacy"}},
+		{Distro: "team", Order: 1, Path: "b.patch", File: "not-used/01-b.patch", State: PatchDisabled, Present: true, Reason: "breaks the build"},
+		{Distro: "team", Order: 2, Path: "c.patch", State: PatchUnknown},
+	}, rows)
+
+	// Filters
+	rows, err = c.listPatches(nil, &listOpts{disabled: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, "b.patch", rows[0].Path)
+	rows, err = c.listPatches(nil, &listOpts{tags: []string{"privacy", "ui"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, "core/a.patch", rows[0].Path)
+	rows, err = c.listPatches(nil, &listOpts{missing: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, "c.patch", rows[0].Path)
+
+	// Formats
+	out, err := formatPatchRows(rows, "csv")
+	assert.Nil(t, err)
+	assert.Equal(t, "distro,order,path,file,state,present,source,reason,tags\nteam,2,c.patch,,unknown,false,,,\n", out)
+	out, err = formatPatchRows(rows, "json")
+	assert.Nil(t, err)
+	assert.Contains(t, out, `"state": "unknown"`)
+	_, err = formatPatchRows(rows, "xml")
+	assert.Equal(t, "invalid output format xml, expected one of [table json csv]", err.Error())
+}
@@ -0,0 +1,368 @@
+package chroma
+
+import (
+	"path"
+
+	"github.com/phR0ze/n/pkg/enc/yaml"
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ManifestName is the name of the manifest file expected next to the PKGBUILD
+	ManifestName = "chroma.yaml"
+
+	// ManifestVersion is the manifest format version this build of chroma understands
+	ManifestVersion = 1
+)
+
+// Manifest declares the extensions, patch sets and patch decisions for a chromium package.
+// Values read from the manifest file take precedence over the built-in defaults.
+type Manifest struct {
+	Version    int                  `json:"version"`              // manifest format version
+	Extensions map[string]string    `json:"extensions,omitempty"` // extension name to Google Market id
+	PatchSets  map[string]*PatchSet `json:"patchsets,omitempty"`  // patch set name to patch set
+	Order      []*OrderConstraint   `json:"order,omitempty"`      // ordering constraints between patch sets
+}
+
+// PatchSet declares where a patch set comes from and which of its patches are used
+type PatchSet struct {
+	Source    string   `json:"source,omitempty"`    // patch source type e.g. quilt, git or dir
+	URL       string   `json:"url,omitempty"`       // location of the patch set's order file, repository or directory
+	Path      string   `json:"path,omitempty"`      // sub directory of a repository or directory holding the patches
+	KeepPaths bool     `json:"keepPaths,omitempty"` // keep upstream relative paths, patches are then named by path
+	Rules     []*Rule  `json:"rules,omitempty"`     // rules classifying patches not called out individually
+	Patches   []*Patch `json:"patches,omitempty"`   // patch decisions, order is significant
+
+	toggles map[string]bool  // tags enabled or disabled for a single run overriding decisions
+	version *ChromiumVersion // chromium version being packaged to check decision constraints against
+}
+
+// Patch declares whether a single patch is used and why
+type Patch struct {
+	Name     string   `json:"name"`               // upstream path or base name of the patch e.g. system/vpx.patch
+	Enabled  bool     `json:"enabled"`            // true when the patch should be applied
+	Source   string   `json:"source,omitempty"`   // project the patch originated from e.g. inox or iridium
+	Reason   string   `json:"reason,omitempty"`   // rationale for enabling or disabling the patch
+	Tags     []string `json:"tags,omitempty"`     // categories of the patch e.g. privacy or system-libs
+	Versions string   `json:"versions,omitempty"` // chromium versions the decision applies to e.g. >=78.0.3904 <80
+}
+
+// defaultManifest builds a manifest from the built-in extension, patch set and patch tables
+func defaultManifest() (manifest *Manifest) {
+	manifest = &Manifest{
+		Version:    ManifestVersion,
+		Extensions: map[string]string{},
+		PatchSets:  map[string]*PatchSet{},
+	}
+	for name, id := range gExtensions {
+		manifest.Extensions[name] = id
+	}
+	for name, set := range gPatchSets {
+		manifest.PatchSets[name] = &PatchSet{Source: set.Source, URL: set.URL, Path: set.Path, KeepPaths: set.KeepPaths}
+	}
+	for name, patches := range gPatches {
+		set := manifest.patchSet(name)
+		for _, patch := range patches {
+			x := *patch
+			set.Patches = append(set.Patches, &x)
+		}
+	}
+	for name, rules := range gRules {
+		set := manifest.patchSet(name)
+		for _, rule := range rules {
+			x := *rule
+			set.Rules = append(set.Rules, &x)
+		}
+	}
+	return
+}
+
+// loadManifest reads in the given manifest file and validates its version
+func loadManifest(filepath string) (manifest *Manifest, err error) {
+	var data []byte
+	if data, err = sys.ReadBytes(filepath); err != nil {
+		return
+	}
+	manifest = &Manifest{}
+	if err = yaml.Unmarshal(data, manifest); err != nil {
+		err = errors.Wrapf(err, "failed to parse manifest %s", filepath)
+		return
+	}
+	if manifest.Version != ManifestVersion {
+		err = errors.Errorf("unsupported manifest version %d in %s, expected %d", manifest.Version, filepath, ManifestVersion)
+		return
+	}
+
+	// Validate ordering constraints
+	for i, constraint := range manifest.Order {
+		if constraint == nil || constraint.Patch == "" || constraint.After == "" {
+			err = errors.Errorf("order constraint %d in %s needs both a patch and an after", i, filepath)
+			return
+		}
+	}
+
+	// Validate patch entries
+	for name, set := range manifest.PatchSets {
+		if set == nil {
+			err = errors.Errorf("patch set %s in %s has no content", name, filepath)
+			return
+		}
+		for i, patch := range set.Patches {
+			if patch == nil || patch.Name == "" {
+				err = errors.Errorf("patch %d of patch set %s in %s has no name", i, name, filepath)
+				return
+			}
+			if patch.Versions != "" {
+				if _, err = parseVersionConstraint(patch.Versions); err != nil {
+					err = errors.WithMessagef(err, "patch %s of patch set %s in %s", patch.Name, name, filepath)
+					return
+				}
+			}
+		}
+		for i, rule := range set.Rules {
+			if rule == nil {
+				err = errors.Errorf("rule %d of patch set %s in %s has no content", i, name, filepath)
+				return
+			}
+			if err = rule.validate(); err != nil {
+				err = errors.WithMessagef(err, "rule %d of patch set %s in %s", i, name, filepath)
+				return
+			}
+		}
+	}
+	return
+}
+
+// manifestPath returns the path to the manifest file for the configured package
+func (chroma *Chroma) manifestPath() string {
+	if chroma.manifestFile != "" {
+		return chroma.manifestFile
+	}
+	return path.Join(chroma.rootDir, ManifestName)
+}
+
+// loadManifests builds the working manifest from the defaults overridden by the manifest file
+func (chroma *Chroma) loadManifests() (err error) {
+	chroma.manifest = defaultManifest()
+
+	// The manifest is optional unless it was explicitly called out
+	filepath := chroma.manifestPath()
+	if !sys.Exists(filepath) {
+		if chroma.manifestFile != "" {
+			err = errors.Errorf("manifest %s coudn't be found", filepath)
+		}
+		return
+	}
+
+	var manifest *Manifest
+	if manifest, err = loadManifest(filepath); err != nil {
+		return
+	}
+	chroma.manifest.merge(manifest)
+	return
+}
+
+// versioned returns true if any decision is constrained to chromium versions
+func (manifest *Manifest) versioned() bool {
+	for _, set := range manifest.PatchSets {
+		for _, patch := range set.Patches {
+			if patch.Versions != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setVersion sets the chromium version being packaged that version constrained decisions are
+// checked against.
+func (manifest *Manifest) setVersion(version *ChromiumVersion) {
+	for _, set := range manifest.PatchSets {
+		set.version = version
+	}
+}
+
+// merge the given manifest into this manifest with the given manifest winning
+func (manifest *Manifest) merge(other *Manifest) {
+	for name, id := range other.Extensions {
+		manifest.Extensions[name] = id
+	}
+	for _, constraint := range other.Order {
+		x := *constraint
+		manifest.Order = append(manifest.Order, &x)
+	}
+	for name, otherSet := range other.PatchSets {
+		set := manifest.patchSet(name)
+		if otherSet.Source != "" {
+			set.Source = otherSet.Source
+		}
+		if otherSet.URL != "" {
+			set.URL = otherSet.URL
+		}
+		if otherSet.Path != "" {
+			set.Path = otherSet.Path
+		}
+		if otherSet.KeepPaths {
+			set.KeepPaths = true
+		}
+
+		// Rules merged in come first so that they win over the rules they override at equal priority
+		rules := []*Rule{}
+		for _, rule := range otherSet.Rules {
+			x := *rule
+			rules = append(rules, &x)
+		}
+		set.Rules = append(rules, set.Rules...)
+		for _, patch := range otherSet.Patches {
+			if existing := set.patch(patch.Name); existing != nil {
+
+				// Entries only deciding the state keep the tags and versions they override
+				x := *patch
+				if len(x.Tags) == 0 {
+					x.Tags = existing.Tags
+				}
+				if x.Versions == "" {
+					x.Versions = existing.Versions
+				}
+				*existing = x
+			} else {
+				x := *patch
+				set.Patches = append(set.Patches, &x)
+			}
+		}
+	}
+}
+
+// patchSet returns the named patch set creating it if it doesn't exist
+func (manifest *Manifest) patchSet(name string) (set *PatchSet) {
+	var ok bool
+	if set, ok = manifest.PatchSets[name]; !ok || set == nil {
+		set = &PatchSet{}
+		manifest.PatchSets[name] = set
+	}
+	return
+}
+
+// patch returns the patch with the given name or nil if not found
+func (set *PatchSet) patch(name string) *Patch {
+	for _, patch := range set.Patches {
+		if patch.Name == name {
+			return patch
+		}
+	}
+	return nil
+}
+
+// lookup returns the patch for the given upstream path or local patch file name or nil
+// if not found. Patches are matched by full upstream path first then by base name with
+// and without the local order number prefix.
+func (set *PatchSet) lookup(name string) (patch *Patch) {
+	if patch = set.patch(name); patch == nil {
+		base := path.Base(name)
+		if patch = set.patch(base); patch == nil {
+			if id := patchID(base); id != base {
+				patch = set.patch(id)
+			}
+		}
+	}
+	return
+}
+
+// mapped returns true if the named patch is classified as enabled or disabled in the patch set
+// by a per patch decision or a rule. Unmapped patches are treated as disabled but should be classified.
+func (set *PatchSet) mapped(name string) bool {
+	patch, _ := set.classify(name)
+	return patch != nil
+}
+
+// used returns true if the named patch is enabled in the patch set. Toggled tags win over
+// decisions and rules with disabled tags winning over enabled ones.
+func (set *PatchSet) used(name string) bool {
+	if enabled, ok := set.toggled(name); ok {
+		return enabled
+	}
+	if patch, _ := set.classify(name); patch != nil {
+		return patch.Enabled
+	}
+	return false
+}
+
+// savePatches persists the given patch decisions of the given patch set to the manifest file and
+// the working manifest. Each decision replaces any existing one with the same name. The manifest
+// file is rewritten so comments in it aren't kept.
+func (chroma *Chroma) savePatches(distro string, patches ...*Patch) (err error) {
+	filepath := chroma.manifestPath()
+	manifest := &Manifest{Version: ManifestVersion}
+	if sys.Exists(filepath) {
+		if manifest, err = loadManifest(filepath); err != nil {
+			return
+		}
+	}
+	if manifest.PatchSets == nil {
+		manifest.PatchSets = map[string]*PatchSet{}
+	}
+	for _, set := range []*PatchSet{manifest.patchSet(distro), chroma.manifest.patchSet(distro)} {
+		for _, patch := range patches {
+			x := *patch
+			if existing := set.patch(patch.Name); existing != nil {
+				*existing = x
+			} else {
+				set.Patches = append(set.Patches, &x)
+			}
+		}
+	}
+
+	var data []byte
+	if data, err = yaml.Marshal(manifest); err != nil {
+		err = errors.Wrapf(err, "failed to marshal manifest %s", filepath)
+		return
+	}
+	return chroma.write(filepath, data)
+}
+
+// tags returns the tags of the named patch from its per patch decision and matching rules
+func (set *PatchSet) tags(name string) (tags []string) {
+	add := func(values []string) {
+		for _, tag := range values {
+			if !containsString(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	if patch := set.lookup(name); patch != nil {
+		add(patch.Tags)
+	}
+	for _, rule := range set.matchingRules(name) {
+		add(rule.Tags)
+	}
+	return
+}
+
+// toggleTags enables or disables the patches with the given tags for the rest of the run
+func (set *PatchSet) toggleTags(enable, disable []string) {
+	if set.toggles == nil {
+		set.toggles = map[string]bool{}
+	}
+	for _, tag := range enable {
+		set.toggles[tag] = true
+	}
+	for _, tag := range disable {
+		set.toggles[tag] = false
+	}
+}
+
+// toggled returns the state of the named patch set by toggled tags if any of its tags are
+func (set *PatchSet) toggled(name string) (enabled, ok bool) {
+	if len(set.toggles) == 0 {
+		return
+	}
+	for _, tag := range set.tags(name) {
+		if value, found := set.toggles[tag]; found {
+			if enabled, ok = value, true; !enabled {
+				return
+			}
+		}
+	}
+	return
+}
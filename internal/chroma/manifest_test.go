@@ -0,0 +1,58 @@
+package chroma
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chroma")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Unsupported version
+	filepath := path.Join(dir, ManifestName)
+	assert.Nil(t, ioutil.WriteFile(filepath, []byte("version: 2\n"), 0644))
+	_, err = loadManifest(filepath)
+	assert.Equal(t, "unsupported manifest version 2 in "+filepath+", expected 1", err.Error())
+
+	// Manifest wins over the defaults
+	data := `version: 1
+extensions:
+  foo: abcdef
+patchsets:
+  debian:
+    patches:
+      - name: manpage.patch
+        enabled: false
+        reason: we ship our own
+      - name: custom.patch
+        enabled: true
+`
+	assert.Nil(t, ioutil.WriteFile(filepath, []byte(data), 0644))
+	c := &Chroma{rootDir: dir}
+	assert.Nil(t, c.loadManifests())
+	assert.Equal(t, "abcdef", c.manifest.Extensions["foo"])
+	assert.Equal(t, gExtensions["tampermonkey"], c.manifest.Extensions["tampermonkey"])
+
+	set := c.manifest.PatchSets["debian"]
+	assert.Equal(t, gPatchSets["debian"].URL, set.URL)
+	assert.False(t, set.used("manpage.patch"))
+	assert.Equal(t, "we ship our own", set.patch("manpage.patch").Reason)
+	assert.Equal(t, []string{"debian-branding"}, set.tags("manpage.patch"))
+	assert.True(t, set.used("custom.patch"))
+	assert.True(t, set.used("master-preferences.patch"))
+
+	// Patches are identified by upstream path or base name without the order number
+	assert.True(t, set.used("02-master-preferences.patch"))
+	assert.True(t, set.used("debian/master-preferences.patch"))
+	assert.False(t, set.used("00-manpage.patch"))
+	assert.Nil(t, set.lookup("bogus.patch"))
+
+	// Defaults are left untouched
+	assert.True(t, gPatches["debian"][0].Enabled)
+}
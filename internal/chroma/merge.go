@@ -0,0 +1,131 @@
+package chroma
+
+import (
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// OrderConstraint requires the patches matching Patch to be applied after the patches matching
+// After in the combined series. Both are a patch set name optionally followed by a glob of
+// upstream patch paths e.g. ungoogled or debian/system/*.
+type OrderConstraint struct {
+	Patch string `json:"patch"` // patches to order
+	After string `json:"after"` // patches they must be applied after
+}
+
+// matchPatchPattern returns true if the given patch set name and optional glob matches the given
+// patch. Globs without a directory also match the base name of the upstream path.
+func matchPatchPattern(pattern string, patch *seriesPatch) bool {
+	distro, glob := pattern, ""
+	if i := strings.Index(pattern, "/"); i != -1 {
+		distro, glob = pattern[:i], pattern[i+1:]
+	}
+	if distro != patch.Distro {
+		return false
+	}
+	if glob == "" {
+		return true
+	}
+	names := []string{patch.Path, patch.Name}
+	if path.Dir(glob) == "." {
+		names = append(names, path.Base(patch.Path))
+	}
+	for _, name := range names {
+		if ok, _ := path.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the enabled patches of the given distributions in the combined apply order. Each
+// distribution's own order is kept and the manifest's ordering constraints are applied.
+func (chroma *Chroma) combinedPatches(distros []string) (patches []*seriesPatch, err error) {
+	var sets [][]*seriesPatch
+	for _, distro := range chroma.patchDistros(distros) {
+		var distroPatches []*seriesPatch
+		if distroPatches, err = chroma.enabledPatches(distro); err != nil {
+			return
+		}
+		sets = append(sets, distroPatches)
+	}
+	return mergeSeries(sets, chroma.manifest.Order)
+}
+
+// mergeSeries merges the given ordered patch sets into a single order keeping each set's order
+// and satisfying the given constraints. The merge is a topological sort using Kahn's algorithm
+// where patches ready to be applied are taken by set rank then series order. Without constraints
+// the sets are simply concatenated in the order given.
+func mergeSeries(sets [][]*seriesPatch, constraints []*OrderConstraint) (patches []*seriesPatch, err error) {
+
+	// Nodes are numbered by set rank then series order so the lowest ready node is taken next
+	nodes := []*seriesPatch{}
+	edges := map[int][]int{}
+	deps := map[int]int{}
+	seen := map[[2]int]bool{}
+	edge := func(from, to int) {
+		if from != to && !seen[[2]int{from, to}] {
+			seen[[2]int{from, to}] = true
+			edges[from] = append(edges[from], to)
+			deps[to]++
+		}
+	}
+	for _, set := range sets {
+		for i, patch := range set {
+			nodes = append(nodes, patch)
+			if i > 0 {
+				edge(len(nodes)-2, len(nodes)-1)
+			}
+		}
+	}
+	for _, constraint := range constraints {
+		for from, after := range nodes {
+			if !matchPatchPattern(constraint.After, after) {
+				continue
+			}
+			for to, patch := range nodes {
+				if matchPatchPattern(constraint.Patch, patch) {
+					edge(from, to)
+				}
+			}
+		}
+	}
+
+	ready := []int{}
+	for i := range nodes {
+		if deps[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for len(ready) > 0 {
+		next := 0
+		for i := range ready {
+			if ready[i] < ready[next] {
+				next = i
+			}
+		}
+		node := ready[next]
+		ready = append(ready[:next], ready[next+1:]...)
+		patches = append(patches, nodes[node])
+		for _, to := range edges[node] {
+			if deps[to]--; deps[to] == 0 {
+				ready = append(ready, to)
+			}
+		}
+	}
+
+	// Patches left over are part of or wait on a cycle
+	if len(patches) < len(nodes) {
+		cycle := []string{}
+		for i, patch := range nodes {
+			if deps[i] > 0 {
+				cycle = append(cycle, patch.String())
+			}
+		}
+		patches = nil
+		err = errors.Errorf("ordering constraints form a cycle between %s", strings.Join(cycle, ", "))
+	}
+	return
+}
@@ -0,0 +1,47 @@
+package chroma
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeSeries(t *testing.T) {
+	debian := []*seriesPatch{
+		{Distro: "debian", Name: "00-a.patch", Path: "fixes/a.patch"},
+		{Distro: "debian", Name: "01-vpx.patch", Path: "system/vpx.patch"},
+		{Distro: "debian", Name: "02-b.patch", Path: "fixes/b.patch"},
+	}
+	ungoogled := []*seriesPatch{
+		{Distro: "ungoogled", Name: "00-c.patch", Path: "core/c.patch"},
+		{Distro: "ungoogled", Name: "01-d.patch", Path: "extra/d.patch"},
+	}
+	names := func(patches []*seriesPatch) (result []string) {
+		for _, patch := range patches {
+			result = append(result, patch.String())
+		}
+		return
+	}
+
+	// Without constraints the sets are concatenated
+	patches, err := mergeSeries([][]*seriesPatch{debian, ungoogled}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"debian/00-a.patch", "debian/01-vpx.patch", "debian/02-b.patch", "ungoogled/00-c.patch", "ungoogled/01-d.patch"}, names(patches))
+
+	// Constraints move patches while keeping each set's order
+	patches, err = mergeSeries([][]*seriesPatch{debian, ungoogled}, []*OrderConstraint{
+		{Patch: "debian/b.patch", After: "ungoogled/core/*"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"debian/00-a.patch", "debian/01-vpx.patch", "ungoogled/00-c.patch", "debian/02-b.patch", "ungoogled/01-d.patch"}, names(patches))
+	patches, err = mergeSeries([][]*seriesPatch{debian, ungoogled}, []*OrderConstraint{{Patch: "debian", After: "ungoogled"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "ungoogled/01-d.patch", patches[1].String())
+
+	// Cycles are refused
+	_, err = mergeSeries([][]*seriesPatch{debian, ungoogled}, []*OrderConstraint{
+		{Patch: "debian/system/*", After: "ungoogled/d.patch"},
+		{Patch: "ungoogled/c.patch", After: "debian/02-b.patch"},
+	})
+	assert.Equal(t, "ordering constraints form a cycle between debian/01-vpx.patch, debian/02-b.patch, ungoogled/00-c.patch, ungoogled/01-d.patch", err.Error())
+}
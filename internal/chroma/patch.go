@@ -0,0 +1,249 @@
+package chroma
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/pkg/errors"
+)
+
+const (
+	// NotUsedDir is the patch set sub directory disabled patches are kept in
+	NotUsedDir = "not-used"
+)
+
+var (
+	gRXPatchOrder = regexp.MustCompile(`^[0-9]+-`)
+)
+
+// localPatch is a patch file downloaded into a patch set directory
+type localPatch struct {
+	Name string // path of the patch relative to the used or not-used directory
+	Used bool   // true if the patch is in the used directory
+}
+
+// patchID returns the identity of the given local patch file name which is the name
+// without the order number prefix added when flattening e.g. 05-foo.patch => foo.patch.
+func patchID(name string) string {
+	return gRXPatchOrder.ReplaceAllString(name, "")
+}
+
+// patchFileName returns the local file name for the given entry at the given order index.
+// The order number is only used for ordering, the patch is identified by its upstream path.
+func patchFileName(i int, entry *PatchEntry, keepPaths bool) string {
+	if keepPaths {
+		return entry.Path
+	}
+	return fmt.Sprintf("%02d-%s", i, path.Base(entry.Path))
+}
+
+// localPatches returns the patch files in the given patch set directory and its not-used
+// directory. Hidden directories e.g. cloned sources, the obsolete directory and non patch
+// files are skipped.
+func localPatches(patchSetDir string) (patches []*localPatch, err error) {
+	for _, used := range []bool{true, false} {
+		dir := patchSetDir
+		if !used {
+			dir = path.Join(patchSetDir, NotUsedDir)
+		}
+		if !sys.Exists(dir) {
+			continue
+		}
+		var names []string
+		err = filepath.Walk(dir, func(target string, info os.FileInfo, e error) error {
+			if e != nil {
+				return e
+			}
+			if info.IsDir() {
+				if target != dir && (strings.HasPrefix(info.Name(), ".") || (used && (target == path.Join(dir, NotUsedDir) || target == path.Join(dir, ObsoleteDir)))) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if path.Ext(target) == ".patch" {
+				names = append(names, strings.TrimPrefix(target, dir+"/"))
+			}
+			return nil
+		})
+		if err != nil {
+			err = errors.Wrapf(err, "failed to read patches in %s", dir)
+			return
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			patches = append(patches, &localPatch{Name: name, Used: used})
+		}
+	}
+	return
+}
+
+// localPatches returns the patch files in the given patch set directory taking planned actions
+// into account. Patches planned to be moved are left out as they are reported at their destination.
+func (chroma *Chroma) localPatches(patchSetDir string) (patches []*localPatch, err error) {
+	var local []*localPatch
+	if local, err = localPatches(patchSetDir); err != nil {
+		return
+	}
+	for _, patch := range local {
+		if chroma.exists(path.Join(patchSetDir, patchPath(patch))) {
+			patches = append(patches, patch)
+		}
+	}
+	return
+}
+
+// seriesPatch is an enabled local patch in apply order
+type seriesPatch struct {
+	Distro  string // distribution the patch belongs to
+	Name    string // path of the patch relative to the patch set directory
+	Path    string // upstream path of the patch
+	File    string // absolute path of the patch file
+	Strip   int    // number of leading path components to strip when applying
+	Reverse bool   // true if the patch is applied in reverse
+}
+
+// String returns the distribution qualified name of the patch e.g. debian/05-vpx.patch
+func (patch *seriesPatch) String() string {
+	return path.Join(patch.Distro, patch.Name)
+}
+
+// diffs returns the file diffs of the patch as applied i.e. reversed for reverse patches
+func (patch *seriesPatch) diffs() (diffs []*FileDiff, err error) {
+	if diffs, err = readDiffFile(patch.File); err != nil || !patch.Reverse {
+		return
+	}
+	return reverseDiffs(diffs), nil
+}
+
+// enabledPatches returns the enabled local patches of the given distribution in apply order.
+// The order is the series recorded at the last download. Patches without a recorded series
+// fall back on the order of their numbered file names.
+func (chroma *Chroma) enabledPatches(distro string) (patches []*seriesPatch, err error) {
+	patchSetDir := path.Join(chroma.patchesDir, distro)
+	var state *syncState
+	if state, err = loadSyncState(patchSetDir); err != nil {
+		return
+	}
+	var local []*localPatch
+	if local, err = chroma.localPatches(patchSetDir); err != nil {
+		return
+	}
+
+	// Index the enabled patches by upstream path and identity
+	byName := map[string]*localPatch{}
+	for _, patch := range local {
+		if patch.Used {
+			byName[patch.Name] = patch
+			if path.Dir(patch.Name) == "." {
+				byName[patchID(patch.Name)] = patch
+			}
+		}
+	}
+	add := func(patch *localPatch, upstream string) {
+		delete(byName, patch.Name)
+		delete(byName, patchID(patch.Name))
+		patches = append(patches, &seriesPatch{Distro: distro, Name: patch.Name, Path: upstream,
+			File: path.Join(patchSetDir, patch.Name), Strip: state.strip(upstream), Reverse: state.reversed(upstream)})
+	}
+	for _, upstream := range state.Series {
+		if patch, ok := byName[upstream]; ok {
+			add(patch, upstream)
+		} else if patch, ok := byName[path.Base(upstream)]; ok && path.Dir(patch.Name) == "." {
+			add(patch, upstream)
+		}
+	}
+
+	// Patches not in the recorded series follow in file name order
+	for _, patch := range local {
+		if _, ok := byName[patch.Name]; ok && patch.Used {
+			add(patch, patchID(patch.Name))
+		}
+	}
+	return
+}
+
+// patchDistros returns the given distributions or if none are given the distributions with
+// downloaded patches. The default distributions come first followed by the rest by name.
+func (chroma *Chroma) patchDistros(distros []string) []string {
+	if len(distros) > 0 {
+		return distros
+	}
+	names := []string{}
+	for name := range chroma.manifest.PatchSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range append(append([]string{}, gDefaultDistros...), names...) {
+		if sys.IsDir(path.Join(chroma.patchesDir, name)) && !containsString(distros, name) {
+			distros = append(distros, name)
+		}
+	}
+	return distros
+}
+
+// anyString returns true if any of the given values are in the given slice
+func anyString(values []string, targets []string) bool {
+	for _, target := range targets {
+		if containsString(values, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString returns true if the given string is in the given slice
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// patchFile is a downloaded patch located by name whether enabled or not
+type patchFile struct {
+	seriesPatch
+	Used bool // true if the patch is enabled
+}
+
+// findPatches returns the downloaded patches matching the given name. The name may be the local
+// file name, the patch's identity or its upstream path optionally qualified by the distribution
+// e.g. debian/system/vpx.patch. Without a distribution every downloaded distribution is searched.
+func (chroma *Chroma) findPatches(name string) (patches []*patchFile, err error) {
+	distros := chroma.patchDistros(nil)
+	if i := strings.Index(name, "/"); i != -1 && containsString(distros, name[:i]) {
+		distros = []string{name[:i]}
+		name = name[i+1:]
+	}
+	for _, distro := range distros {
+		patchSetDir := path.Join(chroma.patchesDir, distro)
+		var state *syncState
+		if state, err = loadSyncState(patchSetDir); err != nil {
+			return
+		}
+		var local []*localPatch
+		if local, err = chroma.localPatches(patchSetDir); err != nil {
+			return
+		}
+		for _, patch := range local {
+			upstream := state.upstream(patch.Name)
+			if upstream == "" {
+				upstream = patchID(patch.Name)
+			}
+			if patch.Name != name && patchID(patch.Name) != name && upstream != name {
+				continue
+			}
+			patches = append(patches, &patchFile{Used: patch.Used, seriesPatch: seriesPatch{Distro: distro, Name: patch.Name,
+				Path: upstream, File: path.Join(patchSetDir, patchPath(patch)), Strip: state.strip(upstream),
+				Reverse: state.reversed(upstream)}})
+		}
+	}
+	return
+}
@@ -0,0 +1,122 @@
+package chroma
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func (chroma *Chroma) newPatchesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "patches",
+		Short:   "Inspect the downloaded chromium patches",
+		Aliases: []string{"pa", "patch"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	cmd.AddCommand(
+		func() *cobra.Command {
+			cmd := &cobra.Command{
+				Use:   "info NAME",
+				Short: "Show the headers, diffstat and rationale of a downloaded patch",
+				Long: `Show the headers, diffstat and rationale of a downloaded patch. DEP-3 headers e.g.
+Description, Author, Origin, Bug, Forwarded and Last-Update, git mail headers and free form
+text before the diff are shown along with the files the patch changes and why the patch is
+enabled or disabled. The patch is named by local file name, upstream path or either of these
+qualified by distribution. Every downloaded distribution with a matching patch is shown.
+
+Examples:
+	# Show a debian patch by upstream path
+	chroma patches info debian/system/vpx.patch
+
+	# Show every downloaded patch named vpx.patch
+	chroma patches info vpx.patch
+`,
+				Args: cobra.ExactArgs(1),
+				RunE: func(cmd *cobra.Command, args []string) (err error) {
+					if err = chroma.configure(); err != nil {
+						return
+					}
+					return chroma.patchInfo(args[0])
+				},
+			}
+			return cmd
+		}(),
+	)
+	return cmd
+}
+
+// patchInfo prints out the headers, diffstat and rationale of the named patches
+func (chroma *Chroma) patchInfo(name string) (err error) {
+	var patches []*patchFile
+	if patches, err = chroma.findPatches(name); err != nil {
+		return
+	}
+	if len(patches) == 0 {
+		err = errors.Errorf("no downloaded patch named %s", name)
+		return
+	}
+	for i, patch := range patches {
+		if i > 0 {
+			chroma.println()
+		}
+		if err = chroma.printPatchInfo(patch); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// printPatchInfo prints out the headers, diffstat and rationale of the given patch
+func (chroma *Chroma) printPatchInfo(patch *patchFile) (err error) {
+	var header *PatchHeader
+	if header, err = readPatchHeader(patch.File); err != nil {
+		return
+	}
+	var diffs []*FileDiff
+	if diffs, err = patch.diffs(); err != nil {
+		return
+	}
+
+	// Our own decision for the patch
+	state, reason, source := "disabled", "", ""
+	if patch.Used {
+		state = "enabled"
+	}
+	if set, ok := chroma.manifest.PatchSets[patch.Distro]; ok && set != nil {
+		if decision, _ := set.classify(patch.Path); decision != nil {
+			reason, source = decision.Reason, decision.Source
+		} else {
+			state += " (unmapped)"
+		}
+	}
+	chroma.printf("%-12s %s\n", "Patch:", patch)
+	chroma.printf("%-12s %s\n", "Upstream:", patch.Path)
+	chroma.printf("%-12s %s\n", "State:", state)
+	if source != "" {
+		chroma.printf("%-12s %s\n", "Source:", source)
+	}
+	if reason != "" {
+		chroma.printf("%-12s %s\n", "Reason:", reason)
+	}
+
+	// Upstream headers with continuation lines indented under the field
+	if len(header.Fields) > 0 || len(header.Text) > 0 {
+		chroma.println()
+	}
+	for _, field := range header.Fields {
+		chroma.printf("%s: %s\n", field.Name, strings.Replace(field.Value, "\n", "\n  ", -1))
+	}
+	if len(header.Fields) > 0 && len(header.Text) > 0 {
+		chroma.println()
+	}
+	for _, line := range header.Text {
+		chroma.printf("%s\n", line)
+	}
+
+	chroma.println()
+	chroma.printf("%s", newDiffStat(diffs, patch.Strip))
+	return
+}
@@ -0,0 +1,152 @@
+package chroma
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// PrepareBegin marks the start of the PKGBUILD region chroma generates the patch commands in
+	PrepareBegin = "# BEGIN chroma patches"
+
+	// PrepareEnd marks the end of the PKGBUILD region chroma generates the patch commands in
+	PrepareEnd = "# END chroma patches"
+
+	// DefaultPatchPrefix is the path the PKGBUILD refers to the patches directory by
+	DefaultPatchPrefix = "${startdir}/patches"
+)
+
+type prepareOpts struct {
+	prefix string // path the PKGBUILD refers to the patches directory by
+}
+
+func (chroma *Chroma) newPkgbuildCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "pkgbuild",
+		Short:   "Update the chromium PKGBUILD to match the patches",
+		Aliases: []string{"pkg"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	cmd.AddCommand(
+		func() *cobra.Command {
+			opts := &prepareOpts{}
+			cmd := &cobra.Command{
+				Use:   "prepare [DISTROS]",
+				Short: "Generate the patch commands of the PKGBUILD's prepare function",
+				Long: `Generate the patch commands of the PKGBUILD's prepare function. The region between
+the begin and end marker lines is replaced with a patch command for each enabled patch in the
+merged series order of the given distributions, or all downloaded distributions. Patches marked
+-R in their series are reversed. Everything outside the markers is left untouched and the
+commands are indented like the begin marker.
+
+	prepare() {
+	  cd "${srcdir}/chromium-${pkgver}"
+	  # BEGIN chroma patches
+	  # END chroma patches
+	}
+
+Examples:
+	# Generate the patch commands for the debian and ungoogled patches
+	chroma pkgbuild prepare debian ungoogled
+
+	# Show the change without making it
+	chroma pkgbuild prepare --dry-run
+`,
+				RunE: func(cmd *cobra.Command, args []string) (err error) {
+					if err = chroma.configure(); err != nil {
+						return
+					}
+					return chroma.preparePkgbuild(args, opts)
+				},
+			}
+			cmd.Flags().StringVar(&opts.prefix, "prefix", DefaultPatchPrefix, "Path the PKGBUILD refers to the patches directory by")
+			return cmd
+		}(),
+	)
+	return cmd
+}
+
+// preparePkgbuild replaces the marked region of the PKGBUILD with the patch commands of the
+// given distributions' enabled patches in the merged series order.
+func (chroma *Chroma) preparePkgbuild(distros []string, opts *prepareOpts) (err error) {
+	var patches []*seriesPatch
+	if patches, err = chroma.combinedPatches(distros); err != nil {
+		return
+	}
+	var data string
+	if data, err = sys.ReadString(chroma.pkgbuild); err != nil {
+		err = errors.Wrapf(err, "failed to read the PKGBUILD %s", chroma.pkgbuild)
+		return
+	}
+	var result string
+	if result, err = replacePrepareRegion(data, prepareCommands(patches, opts.prefix)); err != nil {
+		err = errors.WithMessagef(err, "failed to update the PKGBUILD %s", chroma.pkgbuild)
+		return
+	}
+	if result == data {
+		log.Infof("PKGBUILD already applies the %d enabled patches", len(patches))
+		return
+	}
+	log.Infof("Updating the PKGBUILD to apply the %d enabled patches", len(patches))
+	return chroma.write(chroma.pkgbuild, []byte(result))
+}
+
+// prepareCommands returns a patch command for each of the given patches
+func prepareCommands(patches []*seriesPatch, prefix string) (commands []string) {
+	for _, patch := range patches {
+		reverse := ""
+		if patch.Reverse {
+			reverse = "R"
+		}
+		commands = append(commands, fmt.Sprintf(`patch -N%sp%d -i "%s"`, reverse, patch.Strip, path.Join(prefix, patch.String())))
+	}
+	return
+}
+
+// replacePrepareRegion returns the given PKGBUILD with the lines between the begin and end
+// markers replaced by the given lines indented like the begin marker.
+func replacePrepareRegion(data string, lines []string) (result string, err error) {
+	var out []string
+	begin, end := -1, -1
+	for i, line := range strings.Split(data, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == PrepareBegin:
+			if begin != -1 {
+				err = errors.Errorf("line %d: duplicate %q marker", i+1, PrepareBegin)
+				return
+			}
+			begin = i
+			out = append(out, line)
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			for _, l := range lines {
+				out = append(out, indent+l)
+			}
+		case trimmed == PrepareEnd:
+			if begin == -1 || end != -1 {
+				err = errors.Errorf("line %d: %q marker without a preceding %q marker", i+1, PrepareEnd, PrepareBegin)
+				return
+			}
+			end = i
+			out = append(out, line)
+		case begin != -1 && end == -1:
+			// Drop the previously generated lines
+		default:
+			out = append(out, line)
+		}
+	}
+	if begin == -1 || end == -1 {
+		err = errors.Errorf("no %q and %q marker lines found in the prepare function", PrepareBegin, PrepareEnd)
+		return
+	}
+	result = strings.Join(out, "\n")
+	return
+}
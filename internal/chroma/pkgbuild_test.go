@@ -0,0 +1,45 @@
+package chroma
+
+import (
+	"path"
+	"testing"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreparePkgbuild(t *testing.T) {
+	c, dir := newTestPackage(t, "")
+	defer sys.RemoveAll(dir)
+	writeTestFiles(t, dir, map[string]string{
+		"PKGBUILD":                     "pkgname=chromium\npkgver=76.0.3809.100\n\nprepare() {\n  cd chromium\n  # BEGIN chroma patches\n  patch -Np1 -i old.patch\n  # END chroma patches\n  sed -i foo bar\n}\n",
+		"patches/debian/.sync.json":    `{"series":["a.patch","b.patch"],"strips":{"b.patch":0},"reversed":["a.patch"]}`,
+		"patches/debian/00-a.patch":    "a",
+		"patches/debian/01-b.patch":    "b",
+		"patches/ungoogled/00-c.patch": "c",
+	})
+
+	// Only the marked region changes
+	assert.Nil(t, c.preparePkgbuild(nil, &prepareOpts{prefix: DefaultPatchPrefix}))
+	data, err := sys.ReadString(path.Join(dir, "PKGBUILD"))
+	assert.Nil(t, err)
+	assert.Equal(t, `pkgname=chromium
+pkgver=76.0.3809.100
+
+prepare() {
+  cd chromium
+  # BEGIN chroma patches
+  patch -NRp1 -i "${startdir}/patches/debian/00-a.patch"
+  patch -Np0 -i "${startdir}/patches/debian/01-b.patch"
+  patch -Np1 -i "${startdir}/patches/ungoogled/00-c.patch"
+  # END chroma patches
+  sed -i foo bar
+}
+`, data)
+
+	// Missing and unbalanced markers are refused
+	_, err = replacePrepareRegion("prepare() {\n}\n", nil)
+	assert.Equal(t, `no "# BEGIN chroma patches" and "# END chroma patches" marker lines found in the prepare function`, err.Error())
+	_, err = replacePrepareRegion("# END chroma patches\n# BEGIN chroma patches\n", nil)
+	assert.Equal(t, `line 1: "# END chroma patches" marker without a preceding "# BEGIN chroma patches" marker`, err.Error())
+}
@@ -0,0 +1,126 @@
+package chroma
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// PlanVersion is the current version of the plan file format
+	PlanVersion = 1
+)
+
+// Plan is the ordered list of actions chroma would perform for a PKGBUILD. Paths are
+// relative to the package root so that the plan can be reviewed and applied elsewhere.
+type Plan struct {
+	Version     int               `json:"version"`           // plan file format version
+	ChromiumVer string            `json:"chromiumVer"`       // chromium version of the PKGBUILD the plan was made for
+	Actions     []*Action         `json:"actions"`           // actions to perform in order
+	Sources     map[string]string `json:"sources,omitempty"` // patch source types used rather than the manifest's by patch set
+}
+
+type planOpts struct {
+	output string // path to write the plan to
+	downloadOpts
+}
+
+func (chroma *Chroma) newPlanCmd() *cobra.Command {
+	opts := &planOpts{}
+	cmd := &cobra.Command{
+		Use:   "plan [DISTROS]",
+		Short: "Write out the actions chroma would perform as a JSON plan",
+		Long: `Write out the actions chroma would perform as a JSON plan. The plan covers every
+download, move, prune and file generation needed to bring the extensions and the given
+distributions' patches in line with the manifest. Review the plan then run it with apply.
+
+Examples:
+	# Plan the debian and ungoogled patches and all extensions
+	chroma plan
+
+	# Plan the debian patches revalidating upstream and pruning dropped patches
+	chroma plan debian --refresh --prune -o debian.json
+
+	# Plan a team patch set from a local directory failing on unclassified patches
+	chroma plan team --source dir --fail-on-unmapped
+`,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			if err = chroma.configure(); err != nil {
+				return
+			}
+			chroma.dryrun = true
+			if err = chroma.downloadExtensions(nil, &opts.downloadOpts); err != nil {
+				return
+			}
+			if err = chroma.downloadPatches(args, &opts.downloadOpts); err != nil {
+				return
+			}
+			return chroma.writePlan(opts.output)
+		},
+	}
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "plan.json", "Path to write the plan to")
+	cmd.Flags().BoolVar(&opts.clean, "clean", false, "Remove local files before downloading")
+	opts.addPatchFlags(cmd)
+	return cmd
+}
+
+// Write the planned actions to the given plan file with paths relative to the package root
+func (chroma *Chroma) writePlan(filepath string) (err error) {
+	plan := &Plan{Version: PlanVersion, ChromiumVer: chroma.chromiumVer, Actions: []*Action{}, Sources: chroma.sources}
+	for _, action := range chroma.plan {
+		relative := *action
+		relative.Dst = chroma.relPath(action.Dst)
+		if action.Kind == ActionMove || action.Kind == ActionGenerate {
+			relative.Src = chroma.relPath(action.Src)
+		}
+		plan.Actions = append(plan.Actions, &relative)
+	}
+
+	var data []byte
+	if data, err = json.MarshalIndent(plan, "", "  "); err != nil {
+		err = errors.Wrap(err, "failed to marshal plan")
+		return
+	}
+	if err = sys.WriteBytes(filepath, append(data, '\n')); err != nil {
+		return
+	}
+	chroma.printf("Plan with %d actions written to %s\n", len(plan.Actions), filepath)
+	return
+}
+
+// Read the given plan file resolving its paths against the package root
+func (chroma *Chroma) readPlan(filepath string) (plan *Plan, err error) {
+	var data []byte
+	if data, err = sys.ReadBytes(filepath); err != nil {
+		return
+	}
+	plan = &Plan{}
+	if err = json.Unmarshal(data, plan); err != nil {
+		err = errors.Wrapf(err, "failed to parse plan %s", filepath)
+		return
+	}
+	if plan.Version != PlanVersion {
+		err = errors.Errorf("unsupported plan version %d in %s, expected %d", plan.Version, filepath, PlanVersion)
+		return
+	}
+	for i, action := range plan.Actions {
+		if action == nil || action.Dst == "" || path.IsAbs(action.Dst) || strings.HasPrefix(action.Dst, "..") {
+			err = errors.Errorf("invalid action %d in plan %s", i+1, filepath)
+			return
+		}
+		action.Dst = path.Join(chroma.rootDir, action.Dst)
+		if action.Kind == ActionMove || action.Kind == ActionGenerate {
+			action.Src = path.Join(chroma.rootDir, action.Src)
+		}
+	}
+	return
+}
+
+// relPath returns the given path relative to the package root
+func (chroma *Chroma) relPath(target string) string {
+	return strings.TrimPrefix(target, chroma.rootDir+"/")
+}
@@ -0,0 +1,159 @@
+package chroma
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanApply(t *testing.T) {
+	c, dir := newTestPackage(t, `version: 1
+patchsets:
+  team:
+    source: dir
+    url: team
+    patches:
+      - name: b.patch
+        enabled: true
+`)
+	defer sys.RemoveAll(dir)
+	writeTestFiles(t, path.Join(dir, "team"), map[string]string{
+		"series":  "a.patch\nb.patch\n",
+		"a.patch": "a",
+		"b.patch": "b",
+	})
+
+	// Plan paths are relative to the package root
+	planFile := path.Join(dir, "plan.json")
+	c.dryrun = true
+	assert.Nil(t, c.downloadPatches([]string{"team"}, &downloadOpts{}))
+	assert.Nil(t, c.writePlan(planFile))
+	plan, err := c.readPlan(planFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "76.0.3809.100", plan.ChromiumVer)
+	assert.Equal(t, &Action{Kind: ActionDownload, Set: "team", Src: "b.patch", Dst: path.Join(dir, "patches/team/01-b.patch")}, plan.Actions[2])
+
+	// Applying performs exactly the plan
+	c.dryrun, c.plan, c.planned = false, nil, nil
+	assert.Nil(t, c.applyPlan(planFile))
+	data, err := sys.ReadString(path.Join(dir, "patches/team/01-b.patch"))
+	assert.Nil(t, err)
+	assert.Equal(t, "b", data)
+	assert.True(t, sys.Exists(path.Join(dir, "patches/team/not-used/00-a.patch")))
+	state, err := loadSyncState(path.Join(dir, "patches/team"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a.patch", "b.patch"}, state.Series)
+
+	// Applying again is refused as the disk no longer matches the plan
+	err = c.applyPlan(planFile)
+	assert.Equal(t, "plan no longer matches the disk at action 1 mkdir patches/team/not-used: patches/team/not-used already exists", err.Error())
+
+	// Changed files are detected
+	c.dryrun, c.plan, c.planned = true, nil, nil
+	c.manifest.PatchSets["team"].patch("b.patch").Enabled = false
+	assert.Nil(t, c.sortPatches("team", c.manifest.PatchSets["team"]))
+	assert.Nil(t, c.writePlan(planFile))
+	c.dryrun, c.plan, c.planned = false, nil, nil
+	assert.Nil(t, sys.WriteString(path.Join(dir, "patches/team/01-b.patch"), "changed"))
+	err = c.applyPlan(planFile)
+	assert.Equal(t, "plan no longer matches the disk at action 1 move patches/team/01-b.patch => patches/team/not-used/01-b.patch: patches/team/01-b.patch has changed", err.Error())
+}
+
+func TestPlanFlags(t *testing.T) {
+	c := newChroma()
+
+	// Every patch download flag can be planned
+	patches := subCommand(c.newDownloadCmd(), "patches")
+	plan := c.newPlanCmd()
+	for _, name := range []string{"keep-paths", "source", "refresh", "max-age", "prune", "delete", "fail-on-unmapped"} {
+		assert.NotNil(t, patches.Flags().Lookup(name), name)
+		assert.NotNil(t, plan.Flags().Lookup(name), name)
+	}
+}
+
+func TestApplyPlanSeries(t *testing.T) {
+	series := "a.patch\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path.Base(r.URL.Path) == "series" {
+			fmt.Fprint(w, series)
+			return
+		}
+		fmt.Fprint(w, path.Base(r.URL.Path))
+	}))
+	defer server.Close()
+	c, dir := newTestPackage(t, fmt.Sprintf(`version: 1
+patchsets:
+  team:
+    source: quilt
+    url: %s/series
+    rules:
+      - match: "*"
+        enabled: true
+`, server.URL))
+	defer sys.RemoveAll(dir)
+	planFile := path.Join(dir, "plan.json")
+	c.dryrun = true
+	assert.Nil(t, c.downloadPatches([]string{"team"}, &downloadOpts{}))
+	assert.Nil(t, c.writePlan(planFile))
+
+	// Upstream changes after planning aren't picked up when applying
+	series = "a.patch\nb.patch\n"
+	c.dryrun, c.plan, c.planned = false, nil, nil
+	assert.Nil(t, c.applyPlan(planFile))
+	data, _ := sys.ReadString(path.Join(dir, "patches/team/series"))
+	assert.Equal(t, "a.patch\n", data)
+	assert.True(t, sys.Exists(path.Join(dir, "patches/team/00-a.patch")))
+	assert.False(t, sys.Exists(path.Join(dir, "patches/team/01-b.patch")))
+	j, err := loadJournal(dir)
+	assert.Nil(t, err)
+	for _, entry := range j.Runs[len(j.Runs)-1].Entries {
+		assert.NotNil(t, entry)
+	}
+	assert.Equal(t, len(c.plan), len(j.Runs[len(j.Runs)-1].Entries))
+
+	// Plans with patches missing from the series are refused before anything is performed
+	assert.Nil(t, c.undo())
+	data, _ = sys.ReadString(planFile)
+	assert.Nil(t, sys.WriteString(planFile, strings.Replace(data, `"src": "a.patch"`, `"src": "c.patch"`, 1)))
+	c.plan, c.planned = nil, nil
+	err = c.applyPlan(planFile)
+	assert.Equal(t, "plan no longer matches the series at action 3 download team:c.patch => patches/team/00-a.patch: c.patch isn't in the team series", err.Error())
+	assert.False(t, sys.Exists(path.Join(dir, "patches/team")))
+}
+
+func TestPlanApplySource(t *testing.T) {
+	c, dir := newTestPackage(t, `version: 1
+patchsets:
+  team:
+    url: team
+    patches:
+      - name: a.patch
+        enabled: true
+`)
+	defer sys.RemoveAll(dir)
+	writeTestFiles(t, path.Join(dir, "team"), map[string]string{
+		"series":  "a.patch\n",
+		"a.patch": "a",
+	})
+
+	// The patch source type given when planning is used when applying
+	planFile := path.Join(dir, "plan.json")
+	c.dryrun = true
+	assert.Nil(t, c.downloadPatches([]string{"team"}, &downloadOpts{source: "dir"}))
+	assert.Nil(t, c.writePlan(planFile))
+	plan, err := c.readPlan(planFile)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"team": "dir"}, plan.Sources)
+
+	c.dryrun, c.plan, c.planned = false, nil, nil
+	assert.Nil(t, c.applyPlan(planFile))
+	data, err := sys.ReadString(path.Join(dir, "patches/team/00-a.patch"))
+	assert.Nil(t, err)
+	assert.Equal(t, "a", data)
+}
@@ -0,0 +1,89 @@
+package chroma
+
+import (
+	"path"
+
+	"github.com/phR0ze/n/pkg/sys"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ObsoleteDir is the patch set sub directory pruned patches are moved to
+	ObsoleteDir = "obsolete"
+)
+
+type pruneOpts struct {
+	prune  bool // move patches no longer in the series to the obsolete directory
+	delete bool // delete pruned patches rather than moving them to the obsolete directory
+}
+
+// obsoletePatches returns the local patches in the given patch set directory that are no
+// longer referenced by the given upstream series paths. Patches are matched by upstream
+// path when upstream paths are kept or by base name without the order number when flattened.
+func (chroma *Chroma) obsoletePatches(patchSetDir string, series []string) (patches []*localPatch, err error) {
+	paths := map[string]bool{}
+	bases := map[string]bool{}
+	for _, name := range series {
+		paths[name] = true
+		bases[path.Base(name)] = true
+	}
+
+	var local []*localPatch
+	if local, err = chroma.localPatches(patchSetDir); err != nil {
+		return
+	}
+	for _, patch := range local {
+		if paths[patch.Name] || (path.Dir(patch.Name) == "." && bases[patchID(patch.Name)]) {
+			continue
+		}
+		patches = append(patches, patch)
+	}
+	return
+}
+
+// List the local patches for the given distribution that upstream has dropped from the given
+// series and prune them if requested by moving them to the obsolete directory or deleting them.
+func (chroma *Chroma) prunePatches(distro string, series []string, opts *pruneOpts) (err error) {
+	patchSetDir := path.Join(chroma.patchesDir, distro)
+	var patches []*localPatch
+	if patches, err = chroma.obsoletePatches(patchSetDir, series); err != nil {
+		return
+	}
+	if len(patches) == 0 {
+		return
+	}
+
+	chroma.printf("Patches no longer in the %s series:\n", distro)
+	for _, patch := range patches {
+		chroma.printf("  %s\n", patchPath(patch))
+	}
+	if !opts.prune {
+		chroma.printf("Use --prune to move them to %s/ or --prune --delete to remove them\n", ObsoleteDir)
+		return
+	}
+
+	for _, patch := range patches {
+		src := path.Join(patchSetDir, patchPath(patch))
+		if opts.delete {
+			log.Infof("Deleting obsolete patch %s", sys.SlicePath(src, -3, -1))
+			if err = chroma.remove(src); err != nil {
+				return
+			}
+		} else {
+			dst := path.Join(patchSetDir, ObsoleteDir, patch.Name)
+			log.Infof("Pruning obsolete patch %s => %s", patchPath(patch), sys.SlicePath(dst, -3, -1))
+			if err = chroma.move(src, dst); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// patchPath returns the path of the given local patch relative to the patch set directory
+func patchPath(patch *localPatch) string {
+	if patch.Used {
+		return patch.Name
+	}
+	return path.Join(NotUsedDir, patch.Name)
+}
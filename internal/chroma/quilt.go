@@ -0,0 +1,45 @@
+package chroma
+
+import (
+	"path"
+
+	"github.com/phR0ze/n/pkg/net"
+	log "github.com/sirupsen/logrus"
+)
+
+// quiltSource provides patches listed in a quilt series file served over HTTP
+type quiltSource struct {
+	uri         string   // url of the series file
+	patchSetDir string   // local patch set directory to cache the series file in
+	fetcher     *fetcher // fetcher to download with
+}
+
+func newQuiltSource(opts *PatchSourceOpts) (PatchSource, error) {
+	return &quiltSource{uri: opts.Set.URL, patchSetDir: opts.PatchSetDir, fetcher: opts.fetcher}, nil
+}
+
+// Entries returns the patches listed in the series file
+func (source *quiltSource) Entries() (entries []*PatchEntry, err error) {
+	return readOrderFile(source.fetcher, source.uri, source.patchSetDir)
+}
+
+// Fetch downloads the given patch relative to the series file
+func (source *quiltSource) Fetch(entry *PatchEntry, dst string) (err error) {
+	_, err = source.fetcher.fetch(net.JoinURL(net.DirURL(source.uri), entry.Path), dst)
+	return
+}
+
+// Read the order files from disk, downloading if it doesn't exist or has changed upstream
+func readOrderFile(fetcher *fetcher, uri, patchSetDir string) (entries []*PatchEntry, err error) {
+
+	// Read in the patch order file, downloading if needed
+	orderFile := path.Join(patchSetDir, path.Base(uri))
+	var written bool
+	if written, err = fetcher.fetchCache(uri, orderFile); err != nil {
+		return
+	}
+	if written {
+		log.Infof("Downloaded patch order file %s", uri)
+	}
+	return readSeriesFile(orderFile)
+}
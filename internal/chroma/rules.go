@@ -0,0 +1,107 @@
+package chroma
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Rule classifies every patch of a patch set matching a glob or regular expression. Rules let
+// new upstream patches be classified without calling each one out.
+type Rule struct {
+	Match    string   `json:"match,omitempty"`    // glob matched against the upstream path e.g. system/*
+	Regex    string   `json:"regex,omitempty"`    // regular expression matched against the upstream path
+	Enabled  bool     `json:"enabled"`            // true when matching patches should be applied
+	Priority int      `json:"priority,omitempty"` // rules with a higher priority are evaluated first
+	Reason   string   `json:"reason,omitempty"`   // rationale for enabling or disabling matching patches
+	Tags     []string `json:"tags,omitempty"`     // tags of matching patches e.g. system-libs
+
+	rx *regexp.Regexp // compiled regular expression
+}
+
+// String returns the rule's matcher e.g. system/* or /^system\//
+func (rule *Rule) String() string {
+	if rule.Regex != "" {
+		return "/" + rule.Regex + "/"
+	}
+	return rule.Match
+}
+
+// validate checks the rule has exactly one valid matcher
+func (rule *Rule) validate() (err error) {
+	switch {
+	case rule.Match == "" && rule.Regex == "":
+		err = errors.New("rule has no match or regex")
+	case rule.Match != "" && rule.Regex != "":
+		err = errors.Errorf("rule %s has both a match and a regex", rule.Match)
+	case rule.Match != "":
+		if _, err = path.Match(rule.Match, ""); err != nil {
+			err = errors.Wrapf(err, "invalid rule glob %s", rule.Match)
+		}
+	default:
+		if rule.rx, err = regexp.Compile(rule.Regex); err != nil {
+			err = errors.Wrapf(err, "invalid rule regex %s", rule.Regex)
+		}
+	}
+	return
+}
+
+// matches returns true if the rule matches the given upstream patch path. Globs without a
+// directory also match the base name of the path.
+func (rule *Rule) matches(name string) bool {
+	if rule.Regex != "" {
+		if rule.rx == nil {
+			if rule.rx, _ = regexp.Compile(rule.Regex); rule.rx == nil {
+				return false
+			}
+		}
+		return rule.rx.MatchString(name)
+	}
+	if ok, _ := path.Match(rule.Match, name); ok {
+		return true
+	}
+	if path.Dir(rule.Match) == "." {
+		ok, _ := path.Match(rule.Match, path.Base(name))
+		return ok
+	}
+	return false
+}
+
+// matchingRules returns the rules matching the given upstream patch path in evaluation order
+// i.e. by descending priority then declaration order.
+func (set *PatchSet) matchingRules(name string) (rules []*Rule) {
+	for _, rule := range set.Rules {
+		if rule.matches(name) {
+			rules = append(rules, rule)
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority > rules[j].Priority
+	})
+	return
+}
+
+// classify returns the decision for the given upstream patch path and the rule that made it if
+// any. Per patch decisions win over rules. Nil is returned for patches neither classifies.
+// Decisions constrained to chromium versions other than the one being packaged disable the patch.
+func (set *PatchSet) classify(name string) (patch *Patch, rule *Rule) {
+	if patch = set.lookup(name); patch != nil {
+		if patch.Versions != "" && set.version != nil {
+			if constraint, err := parseVersionConstraint(patch.Versions); err == nil && !constraint.Check(set.version) {
+				x := *patch
+				x.Enabled = false
+				x.Reason = fmt.Sprintf("only applies to chromium %s not %s", constraint, set.version)
+				patch = &x
+			}
+		}
+		return
+	}
+	if rules := set.matchingRules(name); len(rules) > 0 {
+		rule = rules[0]
+		patch = &Patch{Name: name, Enabled: rule.Enabled, Reason: rule.Reason}
+	}
+	return
+}
@@ -0,0 +1,113 @@
+package chroma
+
+import (
+	"path"
+	"testing"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	set := &PatchSet{
+		Rules: []*Rule{
+			{Match: "system/*", Enabled: false, Reason: "system libs"},
+			{Regex: `^system/(icu|zlib)\.patch$`, Enabled: true, Priority: 10, Reason: "needed libs"},
+			{Match: "*-buildfix.patch", Enabled: true},
+		},
+		Patches: []*Patch{{Name: "zlib.patch", Enabled: false, Reason: "override"}},
+	}
+
+	// Rules by priority
+	patch, rule := set.classify("system/vpx.patch")
+	assert.Equal(t, &Patch{Name: "system/vpx.patch", Reason: "system libs"}, patch)
+	assert.Equal(t, set.Rules[0], rule)
+	patch, rule = set.classify("system/icu.patch")
+	assert.True(t, patch.Enabled)
+	assert.Equal(t, set.Rules[1], rule)
+	assert.Equal(t, []*Rule{set.Rules[1], set.Rules[0]}, set.matchingRules("system/icu.patch"))
+
+	// Globs without a directory match base names
+	assert.True(t, set.used("fixes/skia-buildfix.patch"))
+
+	// Per patch decisions win
+	patch, rule = set.classify("system/zlib.patch")
+	assert.Equal(t, "override", patch.Reason)
+	assert.Nil(t, rule)
+	assert.False(t, set.mapped("other.patch"))
+
+	// Validation
+	assert.Equal(t, "rule has no match or regex", (&Rule{}).validate().Error())
+	assert.Contains(t, (&Rule{Regex: "("}).validate().Error(), "invalid rule regex (")
+	assert.Contains(t, (&Rule{Match: "["}).validate().Error(), "invalid rule glob [")
+}
+
+func TestManifestRules(t *testing.T) {
+	c, dir := newTestPackage(t, `version: 1
+patchsets:
+  debian:
+    rules:
+      - match: system/*
+        enabled: true
+        reason: we build with bundled libs
+`)
+	defer sys.RemoveAll(dir)
+
+	// Manifest rules win over the built-in rules they override at equal priority
+	set := c.manifest.PatchSets["debian"]
+	patch, rule := set.classify("system/newlib.patch")
+	assert.True(t, patch.Enabled)
+	assert.Equal(t, "we build with bundled libs", rule.Reason)
+	assert.Equal(t, 2, len(set.matchingRules("system/newlib.patch")))
+}
+
+func TestExplain(t *testing.T) {
+	c, dir := newTestPackage(t, `version: 1
+patchsets:
+  team:
+    rules:
+      - match: system/*
+        enabled: false
+        reason: system libs
+`)
+	defer sys.RemoveAll(dir)
+	writeTestFiles(t, path.Join(dir, "patches/team"), map[string]string{
+		".sync.json":   `{"series":["system/vpx.patch"]}`,
+		"00-vpx.patch": "vpx",
+	})
+
+	// New upstream patches are classified and sorted by rule
+	assert.Nil(t, c.sortPatches("team", c.manifest.PatchSets["team"]))
+	assert.True(t, sys.Exists(path.Join(dir, "patches/team/not-used/00-vpx.patch")))
+
+	explanations, err := c.explain("vpx.patch")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(explanations))
+	assert.Equal(t, "system/*", explanations[0].Rule.String())
+	assert.False(t, explanations[0].Decision.Enabled)
+	out := captureStdout(t, func() { c.printExplanations(explanations) })
+	assert.Contains(t, out, "Decided:   rule system/*\n")
+	assert.Contains(t, out, "Reason:    system libs\n")
+	assert.Contains(t, out, "  * system/*                       priority 0    disabled  system libs\n")
+
+	// Invalid rules are refused
+	writeTestFiles(t, dir, map[string]string{ManifestName: "version: 1\npatchsets:\n  team:\n    rules:\n      - regex: \"(\"\n"})
+	assert.NotNil(t, c.configure())
+}
+
+func TestToggleTags(t *testing.T) {
+	set := &PatchSet{
+		Rules: []*Rule{{Match: "system/*", Enabled: false, Tags: []string{"system-libs"}}},
+		Patches: []*Patch{
+			{Name: "a.patch", Enabled: false, Tags: []string{"privacy"}},
+			{Name: "system/icu.patch", Enabled: true, Tags: []string{"privacy"}},
+		},
+	}
+	assert.Equal(t, []string{"privacy", "system-libs"}, set.tags("system/icu.patch"))
+
+	// Toggled tags win over decisions and disabled tags win over enabled ones
+	set.toggleTags([]string{"privacy"}, []string{"system-libs"})
+	assert.True(t, set.used("a.patch"))
+	assert.False(t, set.used("system/icu.patch"))
+	assert.False(t, set.used("b.patch"))
+}
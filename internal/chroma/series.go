@@ -0,0 +1,257 @@
+package chroma
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// DefaultStrip is the strip level quilt uses for patches without a -p option
+	DefaultStrip = 1
+
+	// SeriesName is the name of a quilt series file
+	SeriesName = "series"
+
+	// SeriesTreeMarker is the name of the file marking a directory as a generated series tree
+	SeriesTreeMarker = ".chroma-series"
+)
+
+type seriesOpts struct {
+	output string // path to write the merged series file to
+	tree   string // directory to write the merged series and patches to
+	copy   bool   // copy the patches into the tree rather than symlinking them
+}
+
+func (chroma *Chroma) newSeriesCmd() *cobra.Command {
+	opts := &seriesOpts{}
+	cmd := &cobra.Command{
+		Use:   "series [DISTROS]",
+		Short: "Generate a merged quilt series of the enabled patches",
+		Long: `Generate a merged quilt series of the enabled patches. The enabled patches of the
+given distributions, or all downloaded distributions, are merged into a single series keeping
+each distribution's own order. Distributions follow each other in the order given unless the
+manifest's ordering constraints require otherwise. Patches are named relative to the patches
+directory or optionally written out as a tree of symlinks or copies with its own series file.
+A tree is only written outside the patches directory and only replaces an empty directory or a
+tree written before.
+
+Examples:
+	# Print the merged series of all downloaded distributions
+	chroma series
+
+	# Write the merged debian and ungoogled series next to the patch sets
+	chroma series debian ungoogled -o patches/series
+
+	# Write out a quilt tree of copies of the enabled patches
+	chroma series --tree src/patches --copy
+`,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			if err = chroma.configure(); err != nil {
+				return
+			}
+			return chroma.series(args, opts)
+		},
+	}
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "", "Path to write the merged series file to rather than printing it")
+	cmd.Flags().StringVar(&opts.tree, "tree", "", "Directory to write the merged series and links to the enabled patches to")
+	cmd.Flags().BoolVar(&opts.copy, "copy", false, "Copy the patches into the tree rather than symlinking them")
+	return cmd
+}
+
+// series writes out the merged series of the given distributions' enabled patches
+func (chroma *Chroma) series(distros []string, opts *seriesOpts) (err error) {
+	var patches []*seriesPatch
+	if patches, err = chroma.combinedPatches(distros); err != nil {
+		return
+	}
+	data := formatSeries(patches)
+
+	if opts.tree != "" {
+		if err = chroma.seriesTree(opts.tree, patches, opts.copy); err != nil {
+			return
+		}
+		if err = chroma.write(path.Join(opts.tree, SeriesName), data); err != nil {
+			return
+		}
+	}
+	if opts.output != "" {
+		return chroma.write(opts.output, data)
+	}
+	if opts.tree == "" {
+		chroma.printf("%s", data)
+	}
+	return
+}
+
+// formatSeries returns the quilt series of the given patches named relative to the patches directory
+func formatSeries(patches []*seriesPatch) []byte {
+	var b strings.Builder
+	for _, patch := range patches {
+		b.WriteString(patch.String())
+		if patch.Strip != DefaultStrip {
+			fmt.Fprintf(&b, " -p%d", patch.Strip)
+		}
+		if patch.Reverse {
+			b.WriteString(" -R")
+		}
+		b.WriteString("\n")
+	}
+	return []byte(b.String())
+}
+
+// seriesTree replaces the given directory with a tree of relative symlinks to or copies of the
+// given patches laid out as in the patches directory.
+func (chroma *Chroma) seriesTree(tree string, patches []*seriesPatch, copy bool) (err error) {
+
+	// Refuse the patches directory, anything in it or containing it
+	var abs, patchesDir string
+	if abs, err = sys.Abs(tree); err != nil {
+		return
+	}
+	if patchesDir, err = sys.Abs(chroma.patchesDir); err != nil {
+		return
+	}
+	if abs == patchesDir || strings.HasPrefix(patchesDir, abs+"/") || strings.HasPrefix(abs, patchesDir+"/") {
+		err = errors.Errorf("refusing to write series tree %s as it holds or is in the downloaded patches", tree)
+		return
+	}
+
+	// Only replace empty directories or trees generated before
+	if chroma.exists(tree) {
+		if !chroma.exists(path.Join(tree, SeriesTreeMarker)) {
+			if infos, e := ioutil.ReadDir(tree); e != nil || len(infos) > 0 {
+				err = errors.Errorf("refusing to replace %s as it wasn't generated by chroma series", tree)
+				return
+			}
+		}
+		if err = chroma.remove(tree); err != nil {
+			return
+		}
+	}
+	if err = chroma.write(path.Join(tree, SeriesTreeMarker), []byte("generated by chroma series\n")); err != nil {
+		return
+	}
+	for _, patch := range patches {
+		dst := path.Join(tree, patch.String())
+		if copy {
+			var data []byte
+			if data, err = sys.ReadBytes(patch.File); err != nil {
+				return
+			}
+			if err = chroma.write(dst, data); err != nil {
+				return
+			}
+			continue
+		}
+		var target string
+		if target, err = filepath.Rel(path.Dir(dst), patch.File); err != nil {
+			err = errors.Wrapf(err, "failed to link %s", patch)
+			return
+		}
+		if err = chroma.link(target, dst); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Read the given quilt series file from disk
+func readSeriesFile(seriesFile string) (entries []*PatchEntry, err error) {
+	var file *os.File
+	if file, err = os.Open(seriesFile); err != nil {
+		err = errors.Wrapf(err, "failed to open series file %s", seriesFile)
+		return
+	}
+	defer file.Close()
+	return parseSeries(file, seriesFile)
+}
+
+// Parse a quilt series from the given reader. Each non empty line names a patch optionally
+// followed by patch options e.g. -p0 or -R. Comments start with a # at the beginning of a line
+// or after whitespace. The given name is used to report malformed lines.
+func parseSeries(reader io.Reader, name string) (entries []*PatchEntry, err error) {
+	scanner := bufio.NewScanner(reader)
+	for i := 1; scanner.Scan(); i++ {
+		line := scanner.Text()
+
+		// Drop comments and surrounding whitespace
+		if x := strings.Index(line, "#"); x == 0 {
+			line = ""
+		} else if x := strings.Index(line, " #"); x != -1 {
+			line = line[:x]
+		} else if x := strings.Index(line, "\t#"); x != -1 {
+			line = line[:x]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// Parse the patch options
+		entry := &PatchEntry{Path: fields[0], Strip: DefaultStrip}
+		for j := 1; j < len(fields); j++ {
+			opt := fields[j]
+			switch {
+			case opt == "-R":
+				entry.Reverse = true
+			case strings.HasPrefix(opt, "-p"):
+				level := strings.TrimPrefix(opt, "-p")
+				if level == "" && j+1 < len(fields) {
+					j++
+					level = fields[j]
+				}
+				if entry.Strip, err = parseStrip(level); err != nil {
+					err = errors.Errorf("%s:%d: invalid strip level %q", name, i, level)
+					return
+				}
+			default:
+				err = errors.Errorf("%s:%d: unsupported patch option %q", name, i, opt)
+				return
+			}
+		}
+		if !validSeriesPath(entry.Path) {
+			err = errors.Errorf("%s:%d: invalid patch path %q", name, i, entry.Path)
+			return
+		}
+		entries = append(entries, entry)
+	}
+	if e := scanner.Err(); e != nil {
+		err = errors.Wrapf(e, "failed to read series %s", name)
+	}
+	return
+}
+
+// Validate the given series path is a relative path that stays within the patch set
+func validSeriesPath(target string) bool {
+	if strings.HasPrefix(target, "-") || strings.HasPrefix(target, "/") {
+		return false
+	}
+	for _, piece := range strings.Split(target, "/") {
+		if piece == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse the given strip level. Quilt's ab level is equivalent to a strip level of 1.
+func parseStrip(level string) (strip int, err error) {
+	if level == "ab" {
+		return 1, nil
+	}
+	if strip, err = strconv.Atoi(level); err == nil && strip < 0 {
+		err = errors.Errorf("negative strip level")
+	}
+	return
+}
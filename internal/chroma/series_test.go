@@ -0,0 +1,95 @@
+package chroma
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSeries(t *testing.T) {
+	data := `# Debian series
+manpage.patch
+
+fixes/mojo.patch -p0   # needs strip level 0
+  system/vpx.patch	-p 2
+disable/unrar.patch -R -pab
+	# indented comment
+foo#bar.patch
+`
+	entries, err := parseSeries(strings.NewReader(data), "series")
+	assert.Nil(t, err)
+	assert.Equal(t, []*PatchEntry{
+		{Path: "manpage.patch", Strip: 1},
+		{Path: "fixes/mojo.patch", Strip: 0},
+		{Path: "system/vpx.patch", Strip: 2},
+		{Path: "disable/unrar.patch", Strip: 1, Reverse: true},
+		{Path: "foo#bar.patch", Strip: 1},
+	}, entries)
+
+	// Malformed lines are reported with line numbers
+	_, err = parseSeries(strings.NewReader("a.patch\nb.patch -x\n"), "series")
+	assert.Equal(t, `series:2: unsupported patch option "-x"`, err.Error())
+	_, err = parseSeries(strings.NewReader("a.patch -pfoo\n"), "series")
+	assert.Equal(t, `series:1: invalid strip level "foo"`, err.Error())
+	_, err = parseSeries(strings.NewReader("a.patch\n\n../b.patch\n"), "series")
+	assert.Equal(t, `series:3: invalid patch path "../b.patch"`, err.Error())
+}
+
+func TestSeries(t *testing.T) {
+	c, dir := newTestPackage(t, `version: 1
+order:
+  - patch: debian/b.patch
+    after: ungoogled
+`)
+	defer sys.RemoveAll(dir)
+	writeTestFiles(t, path.Join(dir, "patches"), map[string]string{
+		"debian/.sync.json":          `{"series":["a.patch","b.patch"],"strips":{"b.patch":0}}`,
+		"debian/00-a.patch":          "a",
+		"debian/01-b.patch":          "b",
+		"ungoogled/00-c.patch":       "c",
+		"ungoogled/not-used/d.patch": "d",
+	})
+
+	// Merged series file
+	output := path.Join(dir, "patches", SeriesName)
+	assert.Nil(t, c.series(nil, &seriesOpts{output: output}))
+	data, err := sys.ReadString(output)
+	assert.Nil(t, err)
+	assert.Equal(t, "debian/00-a.patch\nungoogled/00-c.patch\ndebian/01-b.patch -p0\n", data)
+
+	// Symlink and copy trees
+	tree := path.Join(dir, "src/patches")
+	assert.Nil(t, c.series(nil, &seriesOpts{tree: tree}))
+	target, err := os.Readlink(path.Join(tree, "debian/00-a.patch"))
+	assert.Nil(t, err)
+	assert.Equal(t, "../../../patches/debian/00-a.patch", target)
+	data, err = sys.ReadString(path.Join(tree, SeriesName))
+	assert.Nil(t, err)
+	assert.Equal(t, "debian/00-a.patch\nungoogled/00-c.patch\ndebian/01-b.patch -p0\n", data)
+	assert.Nil(t, c.series(nil, &seriesOpts{tree: tree, copy: true}))
+	data, err = sys.ReadString(path.Join(tree, "ungoogled/00-c.patch"))
+	assert.Nil(t, err)
+	assert.Equal(t, "c", data)
+
+	// Trees holding or in the downloaded patches are refused before anything is removed
+	for _, tree := range []string{dir, path.Join(dir, "patches"), path.Join(dir, "patches/debian"), path.Join(dir, "patches/debian/x")} {
+		err = c.series(nil, &seriesOpts{tree: tree})
+		assert.Equal(t, fmt.Sprintf("refusing to write series tree %s as it holds or is in the downloaded patches", tree), err.Error())
+	}
+	assert.True(t, sys.Exists(path.Join(dir, "patches/debian/00-a.patch")))
+
+	// Directories chroma didn't generate are refused unless empty
+	writeTestFiles(t, dir, map[string]string{"src/extensions/ext.crx": "ext"})
+	err = c.series(nil, &seriesOpts{tree: path.Join(dir, "src")})
+	assert.Equal(t, fmt.Sprintf("refusing to replace %s as it wasn't generated by chroma series", path.Join(dir, "src")), err.Error())
+	assert.True(t, sys.Exists(path.Join(dir, "src/extensions/ext.crx")))
+	_, err = sys.MkdirP(path.Join(dir, "empty"))
+	assert.Nil(t, err)
+	assert.Nil(t, c.series(nil, &seriesOpts{tree: path.Join(dir, "empty")}))
+	assert.True(t, sys.Exists(path.Join(dir, "empty", SeriesTreeMarker)))
+}
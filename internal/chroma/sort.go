@@ -1,24 +1,39 @@
 package chroma
 
 import (
+	"fmt"
 	"path"
 
-	"github.com/phR0ze/n"
 	"github.com/phR0ze/n/pkg/sys"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
+type sortOpts struct {
+	pruneOpts
+	enableTags  []string // tags of patches to enable regardless of the manifest
+	disableTags []string // tags of patches to disable regardless of the manifest
+}
+
 func (chroma *Chroma) newSortCmd() *cobra.Command {
+	opts := &sortOpts{}
 	cmd := &cobra.Command{
 		Use:   "sort [DISTROS]",
-		Short: "Enable/disable patches according to the internal mapping",
-		Long: `Enable/disable patches according to the internal mapping
+		Short: "Enable/disable patches according to the manifest",
+		Long: `Enable/disable patches according to the manifest. Patches not called out in the
+chroma.yaml manifest next to the PKGBUILD fallback on the internal mapping.
 
 Examples:
 	
 	# Sort the debian and ungoogled patches
 	chroma sort debian ungoogled
+
+	# Sort the debian patches and delete the ones upstream dropped at the last download
+	chroma sort debian --prune --delete
+
+	# Sort the debian patches enabling the privacy patches and disabling the system lib patches
+	chroma sort debian --enable-tag privacy --disable-tag system-libs
 `,
 		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) (err error) {
@@ -26,43 +41,86 @@ Examples:
 				return
 			}
 			for _, distro := range args {
-				patches := n.Map(gPatches[distro]).Keys().ToStrs()
-				if err = chroma.sortPatches(distro, patches); err != nil {
+				set, ok := chroma.manifest.PatchSets[distro]
+				if !ok {
+					return errors.Errorf("Error: unsupported patch set %s", distro)
+				}
+				set.toggleTags(opts.enableTags, opts.disableTags)
+				if err = chroma.sortPatches(distro, set); err != nil {
+					return
+				}
+				if err = chroma.pruneSortedPatches(distro, &opts.pruneOpts); err != nil {
 					return
 				}
 			}
 			return
 		},
 	}
+	cmd.Flags().BoolVar(&opts.prune, "prune", false, fmt.Sprintf("Move patches no longer in the series to the %s directory", ObsoleteDir))
+	cmd.Flags().BoolVar(&opts.delete, "delete", false, "Delete pruned patches rather than moving them")
+	cmd.Flags().StringSliceVar(&opts.enableTags, "enable-tag", nil, "Enable the patches with these tags regardless of the manifest")
+	cmd.Flags().StringSliceVar(&opts.disableTags, "disable-tag", nil, "Disable the patches with these tags regardless of the manifest, wins over --enable-tag")
 	return cmd
 }
 
-// Enable/disable patches according to the internal gPatches mapping
-func (chroma *Chroma) sortPatches(distro string, patches []string) (err error) {
+// Enable/disable the downloaded patches according to the manifest mapping. If any names are
+// given only the patches decided under those names are sorted.
+func (chroma *Chroma) sortPatches(distro string, set *PatchSet, names ...string) (err error) {
 	patchSetDir := path.Join(chroma.patchesDir, distro)
 
+	var state *syncState
+	if state, err = loadSyncState(patchSetDir); err != nil {
+		return
+	}
+	var patches []*localPatch
+	if patches, err = chroma.localPatches(patchSetDir); err != nil {
+		return
+	}
 	for _, patch := range patches {
 
 		// Set path name to used or not used
-		dstUsedPath := path.Join(patchSetDir, patch)
-		dstNotUsedPath := path.Join(patchSetDir, "not-used", patch)
-		used := gPatches[distro][patch]
+		dstUsedPath := path.Join(patchSetDir, patch.Name)
+		dstNotUsedPath := path.Join(patchSetDir, NotUsedDir, patch.Name)
+		// Patches are classified by upstream path when recorded in the series
+		name := patch.Name
+		if upstream := state.upstream(patch.Name); upstream != "" {
+			name = upstream
+		}
+		if len(names) > 0 {
+			if decision := set.lookup(name); decision == nil || !containsString(names, decision.Name) {
+				continue
+			}
+		}
+		used := set.used(name)
 		switch {
 
 		// Move not used file from used to not used directory
-		case !used && sys.Exists(dstUsedPath):
-			log.Infof("Disabling patch %s => %s", patch, sys.SlicePath(dstUsedPath, -3, -1))
-			if _, err = sys.Move(dstUsedPath, dstNotUsedPath); err != nil {
+		case !used && patch.Used:
+			log.Infof("Disabling patch %s => %s", patch.Name, sys.SlicePath(dstNotUsedPath, -3, -1))
+			if err = chroma.move(dstUsedPath, dstNotUsedPath); err != nil {
 				return
 			}
 
 		// Move used file from not used to used directory
-		case used && sys.Exists(dstNotUsedPath):
-			log.Infof("Enabling patch %s => %s", patch, sys.SlicePath(dstUsedPath, -3, -1))
-			if _, err = sys.Move(dstNotUsedPath, dstUsedPath); err != nil {
+		case used && !patch.Used:
+			log.Infof("Enabling patch %s => %s", patch.Name, sys.SlicePath(dstUsedPath, -3, -1))
+			if err = chroma.move(dstNotUsedPath, dstUsedPath); err != nil {
 				return
 			}
 		}
 	}
 	return
 }
+
+// Prune the given distribution's patches against the series recorded at the last download
+func (chroma *Chroma) pruneSortedPatches(distro string, opts *pruneOpts) (err error) {
+	var state *syncState
+	if state, err = loadSyncState(path.Join(chroma.patchesDir, distro)); err != nil {
+		return
+	}
+	if len(state.Series) == 0 {
+		log.Infof("No series recorded for %s, download the patches to check for obsolete patches", distro)
+		return
+	}
+	return chroma.prunePatches(distro, state.Series, opts)
+}
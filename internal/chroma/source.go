@@ -0,0 +1,88 @@
+package chroma
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// PatchEntry is a single patch in the order given by a patch source
+type PatchEntry struct {
+	Path    string // path of the patch relative to the patch set e.g. system/vpx.patch
+	Strip   int    // number of leading path components to strip when applying e.g. 1 for -p1
+	Reverse bool   // apply the patch in reverse
+	ref     string // source specific reference used to fetch the patch e.g. a commit id
+}
+
+// PatchSource provides the ordered patches of a patch set and fetches them
+type PatchSource interface {
+
+	// Entries returns the patch set's patches in apply order
+	Entries() (entries []*PatchEntry, err error)
+
+	// Fetch writes the given patch to the given destination path
+	Fetch(entry *PatchEntry, dst string) (err error)
+}
+
+// PatchSourceOpts are the options a patch source is created with
+type PatchSourceOpts struct {
+	Set         *PatchSet // patch set to create the source for
+	PatchSetDir string    // directory the patches are downloaded to, may be used to cache order files
+	RootDir     string    // directory with the PKGBUILD that relative local paths are relative to
+	Refresh     bool      // revalidate cached upstream content rather than using it as is
+	fetcher     *fetcher  // fetcher tracking cache validators for the patch set
+}
+
+// PatchSourceFactory creates a patch source with the given options
+type PatchSourceFactory func(opts *PatchSourceOpts) (PatchSource, error)
+
+var (
+	// Registered patch source types
+	gPatchSources = map[string]PatchSourceFactory{
+		"quilt":  newQuiltSource,  // quilt series file over HTTP
+		"git":    newGitSource,    // git repository with or without a series file
+		"dir":    newDirSource,    // local directory with or without a series file
+		"github": newGithubSource, // GitHub repository without a series file
+		"cgit":   newCgitSource,   // cgit commit series
+	}
+)
+
+// RegisterPatchSource makes the given patch source type available to patch sets
+func RegisterPatchSource(kind string, factory PatchSourceFactory) {
+	gPatchSources[kind] = factory
+}
+
+// PatchSourceKinds returns the sorted registered patch source types
+func PatchSourceKinds() (kinds []string) {
+	for kind := range gPatchSources {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return
+}
+
+// newPatchSource creates the patch source for the given options' patch set using the given
+// source type if set else the patch set's source type.
+func newPatchSource(kind string, opts *PatchSourceOpts) (source PatchSource, err error) {
+	set := opts.Set
+	if kind == "" {
+		kind = set.Source
+	}
+	if kind == "" {
+		err = errors.Errorf("no patch source type set, expected one of %v", PatchSourceKinds())
+		return
+	}
+	factory, ok := gPatchSources[kind]
+	if !ok {
+		err = errors.Errorf("unsupported patch source type %s, expected one of %v", kind, PatchSourceKinds())
+		return
+	}
+	if set.URL == "" {
+		err = errors.Errorf("no url set for %s patch source", kind)
+		return
+	}
+	if opts.fetcher == nil {
+		opts.fetcher = newFetcher(&syncState{Files: map[string]*cacheInfo{}}, opts.Refresh)
+	}
+	return factory(opts)
+}
@@ -0,0 +1,58 @@
+package chroma
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPatchSource(t *testing.T) {
+	_, err := newPatchSource("bogus", &PatchSourceOpts{Set: &PatchSet{URL: "foo"}})
+	assert.Equal(t, "unsupported patch source type bogus, expected one of [cgit dir git github quilt]", err.Error())
+
+	_, err = newPatchSource("", &PatchSourceOpts{Set: &PatchSet{Source: "quilt"}})
+	assert.Equal(t, "no url set for quilt patch source", err.Error())
+
+	source, err := newPatchSource("", &PatchSourceOpts{Set: gPatchSets["debian"]})
+	assert.Nil(t, err)
+	assert.IsType(t, &quiltSource{}, source)
+}
+
+func TestDirSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chroma")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Discover patches when there is no series file
+	teamDir := path.Join(dir, "team")
+	for _, x := range []string{"core", ".git"} {
+		_, err = sys.MkdirP(path.Join(teamDir, x))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, sys.WriteString(path.Join(teamDir, "b.patch"), "b"))
+	assert.Nil(t, sys.WriteString(path.Join(teamDir, "core", "a.patch"), "a"))
+	assert.Nil(t, sys.WriteString(path.Join(teamDir, ".git", "c.patch"), "c"))
+	assert.Nil(t, sys.WriteString(path.Join(teamDir, "README.md"), "readme"))
+	source, err := newPatchSource("", &PatchSourceOpts{Set: &PatchSet{Source: "dir", URL: "team"}, PatchSetDir: path.Join(dir, "patches"), RootDir: dir})
+	assert.Nil(t, err)
+	entries, err := source.Entries()
+	assert.Nil(t, err)
+	assert.Equal(t, []*PatchEntry{{Path: "b.patch", Strip: 1}, {Path: "core/a.patch", Strip: 1}}, entries)
+
+	// Fetch copies the patch
+	dst := path.Join(dir, "patches", "team", "00-a.patch")
+	assert.Nil(t, source.Fetch(entries[1], dst))
+	data, err := sys.ReadString(dst)
+	assert.Nil(t, err)
+	assert.Equal(t, "a", data)
+
+	// Series file wins when present
+	assert.Nil(t, sys.WriteLines(path.Join(teamDir, "series"), []string{"core/a.patch", "", "b.patch"}))
+	entries, err = source.Entries()
+	assert.Nil(t, err)
+	assert.Equal(t, []*PatchEntry{{Path: "core/a.patch", Strip: 1}, {Path: "b.patch", Strip: 1}}, entries)
+}
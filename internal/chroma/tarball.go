@@ -0,0 +1,219 @@
+package chroma
+
+import (
+	"archive/tar"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// tarTree is the subset of a chromium source tarball's files needed to check the patches
+type tarTree struct {
+	files map[string][]byte // file content by name relative to the tree root
+}
+
+// ReadFile returns the content of the given file relative to the tree root if it was extracted
+func (tree *tarTree) ReadFile(name string) (data []byte, ok bool, err error) {
+	data, ok = tree.files[name]
+	return
+}
+
+// defaultTarball returns the chromium source tarball makepkg downloads for the PKGBUILD version
+func (chroma *Chroma) defaultTarball() string {
+	return path.Join(chroma.rootDir, fmt.Sprintf("chromium-%s.tar.xz", chroma.chromiumVer))
+}
+
+// Return the names of the files the enabled patches of the given distributions touch
+func (chroma *Chroma) touchedFiles(distros []string) (names map[string]bool, err error) {
+	names = map[string]bool{}
+	var patches []*seriesPatch
+	if patches, err = chroma.combinedPatches(distros); err != nil {
+		return
+	}
+	for _, patch := range patches {
+		var diffs []*FileDiff
+		if diffs, err = patch.diffs(); err != nil {
+			return
+		}
+		for _, diff := range diffs {
+			names[diff.Name(patch.Strip)] = true
+		}
+	}
+	return
+}
+
+// readDiffFile parses the unified diff in the given file
+func readDiffFile(filepath string) (diffs []*FileDiff, err error) {
+	var reader *os.File
+	if reader, err = os.Open(filepath); err != nil {
+		err = errors.Wrapf(err, "failed to open patch %s", filepath)
+		return
+	}
+	defer reader.Close()
+	if diffs, err = parseDiff(reader); err != nil {
+		err = errors.WithMessagef(err, "failed to parse patch %s", filepath)
+	}
+	return
+}
+
+// Stream the given source tarball extracting only the given files into memory. The top level
+// directory of the tarball e.g. chromium-76.0.3809.100/ is stripped from the names. Hard links
+// are resolved to the contents of their targets which are read again if they weren't kept.
+func readTarball(tarball string, names map[string]bool) (tree *tarTree, err error) {
+	tree = &tarTree{files: map[string][]byte{}}
+	var reader io.ReadCloser
+	if reader, err = openTarball(tarball); err != nil {
+		return
+	}
+	defer reader.Close()
+
+	links := map[string]string{} // wanted hard links to their targets that weren't kept
+	archive := tar.NewReader(reader)
+	for len(tree.files)+len(links) < len(names) {
+		var header *tar.Header
+		if header, err = archive.Next(); err != nil {
+			if err == io.EOF {
+				err = nil
+				break
+			}
+			err = errors.Wrapf(err, "failed to read tarball %s", tarball)
+			return
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA && header.Typeflag != tar.TypeLink {
+			continue
+		}
+		name := tarName(header.Name)
+		if !names[name] {
+			continue
+		}
+		if header.Typeflag == tar.TypeLink {
+			target := tarName(header.Linkname)
+			if data, ok := tree.files[target]; ok {
+				tree.files[name] = data
+			} else {
+				links[name] = target
+			}
+			continue
+		}
+		if tree.files[name], err = ioutil.ReadAll(archive); err != nil {
+			err = errors.Wrapf(err, "failed to read %s from tarball %s", name, tarball)
+			return
+		}
+	}
+	if len(links) == 0 {
+		return
+	}
+
+	// Read the hard link targets that weren't kept in another pass
+	targets := map[string]bool{}
+	for _, target := range links {
+		targets[target] = true
+	}
+	var linked *tarTree
+	if linked, err = readTarball(tarball, targets); err != nil {
+		return
+	}
+	for name, target := range links {
+		if data, ok := linked.files[target]; ok {
+			tree.files[name] = data
+		}
+	}
+	return
+}
+
+// tarName returns the given tarball entry name without the top level directory
+func tarName(name string) string {
+	return stripPath(strings.TrimPrefix(name, "./"), 1)
+}
+
+// Open the given tarball decompressing it according to its extension. Xz isn't supported by
+// the standard library so it is streamed through the xz tool.
+func openTarball(tarball string) (reader io.ReadCloser, err error) {
+	if !sys.Exists(tarball) {
+		err = errors.Errorf("chromium source tarball %s doesn't exist", tarball)
+		return
+	}
+	var file *os.File
+	if file, err = os.Open(tarball); err != nil {
+		err = errors.Wrapf(err, "failed to open tarball %s", tarball)
+		return
+	}
+
+	switch {
+	case strings.HasSuffix(tarball, ".tar.xz"):
+		file.Close()
+		return newXzReader(tarball)
+	case strings.HasSuffix(tarball, ".tar.gz") || strings.HasSuffix(tarball, ".tgz"):
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(file); err != nil {
+			file.Close()
+			err = errors.Wrapf(err, "failed to decompress tarball %s", tarball)
+			return
+		}
+		reader = &tarballReader{Reader: gz, close: file.Close}
+	case strings.HasSuffix(tarball, ".tar.bz2"):
+		reader = &tarballReader{Reader: bzip2.NewReader(file), close: file.Close}
+	default:
+		reader = file
+	}
+	return
+}
+
+// tarballReader is a decompressing reader that closes the underlying file
+type tarballReader struct {
+	io.Reader
+	close func() error // closes the underlying resources
+}
+
+// Close the underlying resources
+func (reader *tarballReader) Close() error {
+	return reader.close()
+}
+
+// Stream the given xz compressed file through the xz tool
+func newXzReader(filepath string) (reader io.ReadCloser, err error) {
+	cmd := exec.Command("xz", "--decompress", "--stdout", filepath)
+	cmd.Stderr = os.Stderr
+	var stdout io.ReadCloser
+	if stdout, err = cmd.StdoutPipe(); err != nil {
+		err = errors.Wrap(err, "failed to create xz pipe")
+		return
+	}
+	if err = cmd.Start(); err != nil {
+		err = errors.Wrap(err, "failed to start xz, is it installed?")
+		return
+	}
+
+	// Closing early kills xz as the rest of the stream isn't needed
+	reader = &tarballReader{Reader: stdout, close: func() error {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil
+	}}
+	return
+}
+
+// Check the enabled patches apply to the given chromium source tarball without extracting it.
+// Only the files the patches touch are read from the stream.
+func (chroma *Chroma) checkTarball(tarball string, opts *checkOpts) (err error) {
+	var names map[string]bool
+	if names, err = chroma.touchedFiles(opts.distros); err != nil {
+		return
+	}
+	log.Infof("Reading %d files touched by the patches from %s", len(names), tarball)
+	var tree *tarTree
+	if tree, err = readTarball(tarball, names); err != nil {
+		return
+	}
+	return chroma.checkTree(tree, "", opts)
+}
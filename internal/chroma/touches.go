@@ -0,0 +1,240 @@
+package chroma
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// IndexName is the name of the cached patch hunk index in the journal directory
+	IndexName = "index.json"
+
+	// IndexVersion is the current version of the patch hunk index format
+	IndexVersion = 1
+)
+
+func (chroma *Chroma) newTouchesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "touches PATH|GLOB",
+		Short: "List the downloaded patches that touch the given source paths",
+		Long: `List the downloaded patches that touch the given source paths. Both enabled and
+disabled patches of every downloaded distribution are included along with the line ranges
+their hunks change. A path matches files below it when it is a directory and globs match
+whole path components e.g. chrome/browser/*.cc. The parsed patches are indexed on disk so
+repeated queries only parse patches that changed.
+
+Examples:
+	# List the patches touching a file
+	chroma touches chrome/browser/foo.cc
+
+	# List the patches touching anything under a directory
+	chroma touches chrome/browser/ui
+
+	# List the patches touching the gn files of a directory
+	chroma touches 'build/config/*.gn'
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			if err = chroma.configure(); err != nil {
+				return
+			}
+			var touches []*patchTouch
+			if touches, err = chroma.touches(args[0]); err != nil {
+				return
+			}
+			chroma.printTouches(args[0], touches)
+			return
+		},
+	}
+	return cmd
+}
+
+// patchIndex is the cached hunk ranges of the downloaded patches
+type patchIndex struct {
+	Version int                      `json:"version"` // index format version
+	Patches map[string]*indexedPatch `json:"patches"` // patches by path relative to the patches directory
+}
+
+// indexedPatch is the hunk ranges of a single patch file
+type indexedPatch struct {
+	Size    int64        `json:"size"`              // size of the patch file when indexed
+	ModTime time.Time    `json:"modTime"`           // modification time of the patch file when indexed
+	Strip   int          `json:"strip"`             // strip level the patch was indexed with
+	Reverse bool         `json:"reverse,omitempty"` // true if the patch was indexed in reverse
+	Ranges  []*hunkRange `json:"ranges"`            // ranges the patch's hunks change
+}
+
+// patchTouch is a patch touching a source file
+type patchTouch struct {
+	File    string       // source file touched
+	Distro  string       // distribution of the patch
+	Patch   string       // patch name relative to the used or not-used directory
+	Enabled bool         // true if the patch is enabled
+	Ranges  []*hunkRange // ranges of the file the patch changes
+}
+
+// Return the patches touching source files matching the given path or glob
+func (chroma *Chroma) touches(pattern string) (touches []*patchTouch, err error) {
+	var index *patchIndex
+	if index, err = chroma.loadPatchIndex(); err != nil {
+		return
+	}
+
+	pattern = strings.TrimPrefix(path.Clean(pattern), "./")
+	byKey := map[string]*patchTouch{}
+	for key, patch := range index.Patches {
+		for _, r := range patch.Ranges {
+			if !matchSourcePath(pattern, r.File) {
+				continue
+			}
+			touch := byKey[key+":"+r.File]
+			if touch == nil {
+				touch = &patchTouch{File: r.File, Distro: r.Distro, Patch: r.Patch, Enabled: !strings.HasPrefix(key, path.Join(r.Distro, NotUsedDir)+"/")}
+				byKey[key+":"+r.File] = touch
+				touches = append(touches, touch)
+			}
+			touch.Ranges = append(touch.Ranges, r)
+		}
+	}
+	sort.Slice(touches, func(i, j int) bool {
+		if touches[i].File != touches[j].File {
+			return touches[i].File < touches[j].File
+		}
+		if touches[i].Distro != touches[j].Distro {
+			return touches[i].Distro < touches[j].Distro
+		}
+		return touches[i].Patch < touches[j].Patch
+	})
+	return
+}
+
+// matchSourcePath returns true if the given source file matches the given path or glob. Paths
+// match the file itself or files below them and globs match whole path components.
+func matchSourcePath(pattern, file string) bool {
+	if pattern == file || strings.HasPrefix(file, pattern+"/") {
+		return true
+	}
+	for dir := file; dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if ok, _ := path.Match(pattern, dir); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Load the patch index updating it for the downloaded patches that changed since they were indexed
+func (chroma *Chroma) loadPatchIndex() (index *patchIndex, err error) {
+	indexFile := path.Join(chroma.rootDir, JournalDir, IndexName)
+	index = &patchIndex{Version: IndexVersion, Patches: map[string]*indexedPatch{}}
+	if sys.Exists(indexFile) {
+		var data []byte
+		if data, err = sys.ReadBytes(indexFile); err != nil {
+			return
+		}
+		cached := &patchIndex{}
+		if e := json.Unmarshal(data, cached); e == nil && cached.Version == IndexVersion && cached.Patches != nil {
+			index = cached
+		}
+	}
+
+	// Index new and changed patches dropping the ones that no longer exist
+	changed := false
+	found := map[string]bool{}
+	for _, distro := range chroma.patchDistros(nil) {
+		patchSetDir := path.Join(chroma.patchesDir, distro)
+		var patches []*localPatch
+		if patches, err = localPatches(patchSetDir); err != nil {
+			return
+		}
+		state, e := loadSyncState(patchSetDir)
+		if e != nil {
+			state = &syncState{}
+		}
+		for _, patch := range patches {
+			key := path.Join(distro, patchPath(patch))
+			filepath := path.Join(patchSetDir, patchPath(patch))
+			found[key] = true
+			var info os.FileInfo
+			if info, err = os.Stat(filepath); err != nil {
+				err = errors.Wrapf(err, "failed to stat patch %s", filepath)
+				return
+			}
+
+			// Re-index patches changed on disk or whose series options have changed
+			strip, reverse := patchOptions(state, patch.Name)
+			if cached := index.Patches[key]; cached != nil && cached.Size == info.Size() && cached.ModTime.Equal(info.ModTime()) &&
+				cached.Strip == strip && cached.Reverse == reverse {
+				continue
+			}
+			entry := &indexedPatch{Size: info.Size(), ModTime: info.ModTime(), Strip: strip, Reverse: reverse}
+			if entry.Ranges, err = patchHunkRanges(distro, patch.Name, filepath, strip, reverse); err != nil {
+				return
+			}
+			index.Patches[key] = entry
+			changed = true
+		}
+	}
+	for key := range index.Patches {
+		if !found[key] {
+			delete(index.Patches, key)
+			changed = true
+		}
+	}
+	if !changed || chroma.dryrun {
+		return
+	}
+
+	// Save the updated index
+	var data []byte
+	if data, err = json.Marshal(index); err != nil {
+		err = errors.Wrap(err, "failed to marshal patch index")
+		return
+	}
+	if _, err = sys.MkdirP(path.Dir(indexFile)); err != nil {
+		return
+	}
+	err = sys.WriteBytes(indexFile, data)
+	return
+}
+
+// patchOptions returns the strip level of the given local patch and whether it's applied in
+// reverse from the given recorded series
+func patchOptions(state *syncState, name string) (strip int, reverse bool) {
+	if upstream := state.upstream(name); upstream != "" {
+		return state.strip(upstream), state.reversed(upstream)
+	}
+	return DefaultStrip, false
+}
+
+// Print out the given patches touching the given path grouped by source file
+func (chroma *Chroma) printTouches(pattern string, touches []*patchTouch) {
+	if len(touches) == 0 {
+		chroma.printf("No downloaded patches touch %s\n", pattern)
+		return
+	}
+	file := ""
+	for _, touch := range touches {
+		if touch.File != file {
+			file = touch.File
+			chroma.printf("%s\n", file)
+		}
+		state := "enabled"
+		if !touch.Enabled {
+			state = "disabled"
+		}
+		lines := []string{}
+		for _, r := range touch.Ranges {
+			lines = append(lines, fmt.Sprintf("%d-%d", r.Start, r.End))
+		}
+		chroma.printf("  %-50s %-10s %-9s %s\n", touch.Patch, touch.Distro, state, strings.Join(lines, ", "))
+	}
+}
@@ -0,0 +1,63 @@
+package chroma
+
+import (
+	"path"
+	"testing"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTouches(t *testing.T) {
+	c, dir := newTestPackage(t, "")
+	defer sys.RemoveAll(dir)
+	writeTestFiles(t, path.Join(dir, "patches"), map[string]string{
+		"debian/00-a.patch":             "--- a/chrome/browser/foo.cc\n+++ b/chrome/browser/foo.cc\n@@ -2,2 +2,2 @@\n two\n-three\n+3\n@@ -10,0 +11 @@\n+new\n",
+		"ungoogled/not-used/00-b.patch": "--- a/chrome/browser/foo.cc\n+++ b/chrome/browser/foo.cc\n@@ -5 +5 @@\n-five\n+5\n--- a/build/BUILD.gn\n+++ b/build/BUILD.gn\n@@ -1 +1 @@\n-a\n+b\n",
+	})
+
+	touches, err := c.touches("chrome/browser/foo.cc")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(touches))
+	assert.Equal(t, "00-a.patch", touches[0].Patch)
+	assert.True(t, touches[0].Enabled)
+	assert.Equal(t, []*hunkRange{
+		{Distro: "debian", Patch: "00-a.patch", File: "chrome/browser/foo.cc", Start: 2, End: 3},
+		{Distro: "debian", Patch: "00-a.patch", File: "chrome/browser/foo.cc", Start: 10, End: 10},
+	}, touches[0].Ranges)
+	assert.Equal(t, "ungoogled", touches[1].Distro)
+	assert.False(t, touches[1].Enabled)
+
+	// Directories and globs
+	touches, err = c.touches("chrome")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(touches))
+	touches, err = c.touches("build/*.gn")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(touches))
+	assert.Equal(t, "build/BUILD.gn", touches[0].File)
+
+	// The index is cached and updated when patches are removed
+	assert.True(t, sys.Exists(path.Join(dir, JournalDir, IndexName)))
+	assert.Nil(t, sys.Remove(path.Join(dir, "patches/ungoogled/not-used/00-b.patch")))
+	touches, err = c.touches("build")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(touches))
+
+	// Patches are re-indexed when their recorded strip level changes
+	writeTestFiles(t, path.Join(dir, "patches"), map[string]string{
+		"debian/" + SyncStateName: `{"series": ["a.patch"], "strips": {"a.patch": 0}}`,
+	})
+	touches, err = c.touches("b/chrome/browser/foo.cc")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(touches))
+	assert.Equal(t, "00-a.patch", touches[0].Patch)
+
+	// The index isn't written when making no changes
+	assert.Nil(t, sys.Remove(path.Join(dir, JournalDir, IndexName)))
+	c.dryrun = true
+	touches, err = c.touches("b/chrome")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(touches))
+	assert.False(t, sys.Exists(path.Join(dir, JournalDir, IndexName)))
+}
@@ -0,0 +1,158 @@
+package chroma
+
+import (
+	"os"
+	"path"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func (chroma *Chroma) newUndoCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "undo",
+		Short: "Revert the changes of the last run",
+		Long: `Revert the changes of the last run recorded in the journal under the package root.
+Moves are reversed, downloaded and generated files are removed and removed or overwritten
+files are restored from the trash. Each undo reverts one more run.
+
+Examples:
+	# Revert the last download or sort
+	chroma undo
+
+	# Show what would be reverted
+	chroma undo --dry-run
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			if err = chroma.configure(); err != nil {
+				return
+			}
+			return chroma.undo()
+		},
+
+		// Undo prints its own steps rather than the plan when making no changes
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {},
+	}
+	return cmd
+}
+
+// undoStep is a single step reverting a journal entry
+type undoStep struct {
+	desc string       // human readable description of the step
+	fn   func() error // function performing the step
+}
+
+// Revert the last run recorded in the journal
+func (chroma *Chroma) undo() (err error) {
+	var j *journal
+	if j, err = loadJournal(chroma.rootDir); err != nil {
+		return
+	}
+	if len(j.Runs) == 0 {
+		err = errors.Errorf("nothing to undo")
+		return
+	}
+	run := j.Runs[len(j.Runs)-1]
+	log.Infof("Undoing %s from run %s", run.Command, run.ID)
+
+	// Revert the entries newest first saving progress as we go
+	if chroma.dryrun {
+		chroma.printf("Dry run, the following steps would revert %s:\n", run.Command)
+	}
+	count := 0
+	for i := len(run.Entries) - 1; i >= 0; i-- {
+		if run.Entries[i] == nil || run.Entries[i].Action == nil {
+			log.Warnf("Skipping malformed entry %d of run %s", i+1, run.ID)
+		}
+		for _, step := range chroma.undoSteps(run.Entries[i]) {
+			if count++; chroma.dryrun {
+				chroma.printf("%3d. %s\n", count, step.desc)
+				continue
+			}
+			log.Infof("Reverting: %s", step.desc)
+			if err = step.fn(); err != nil {
+				err = errors.WithMessagef(err, "failed to %s", step.desc)
+				return
+			}
+		}
+		if !chroma.dryrun {
+			run.Entries = run.Entries[:i]
+			if err = j.save(); err != nil {
+				return
+			}
+		}
+	}
+	if chroma.dryrun {
+		return
+	}
+
+	// Drop the run and its trash now that it has been reverted
+	j.Runs = j.Runs[:len(j.Runs)-1]
+	sys.RemoveAll(j.trashDir(run))
+	return j.save()
+}
+
+// Return the steps reverting the given journal entry
+func (chroma *Chroma) undoSteps(entry *journalEntry) (steps []*undoStep) {
+	if entry == nil || entry.Action == nil {
+		return
+	}
+	action := entry.Action
+	rel := chroma.relPath
+	restore := func(src, dst string) func() error {
+		return func() (err error) {
+			if _, err = sys.MkdirP(path.Dir(dst)); err != nil {
+				return
+			}
+			return os.Rename(src, dst)
+		}
+	}
+
+	switch action.Kind {
+	case ActionMkdir:
+		if entry.Created != "" {
+			steps = append(steps, &undoStep{desc: "remove empty " + rel(entry.Created), fn: func() error {
+				return removeEmptyDirs(action.Dst, entry.Created)
+			}})
+		}
+	case ActionMove:
+		steps = append(steps, &undoStep{desc: "move " + rel(action.Dst) + " => " + rel(action.Src), fn: restore(action.Dst, action.Src)})
+	case ActionRemove:
+		if entry.Trash != "" {
+			// Anything left at the removed path was recreated by the run e.g. cache files
+			steps = append(steps, &undoStep{desc: "restore " + rel(action.Dst), fn: func() (err error) {
+				if err = sys.RemoveAll(action.Dst); err != nil {
+					return
+				}
+				return restore(entry.Trash, action.Dst)()
+			}})
+		}
+	case ActionDownload, ActionGenerate, ActionLink, ActionWrite:
+		steps = append(steps, &undoStep{desc: "remove " + rel(action.Dst), fn: func() error {
+			return sys.RemoveAll(action.Dst)
+		}})
+		if entry.Trash != "" {
+			steps = append(steps, &undoStep{desc: "restore " + rel(action.Dst), fn: restore(entry.Trash, action.Dst)})
+		}
+	}
+	return
+}
+
+// Remove the given directory and its parents up to and including the given top most
+// directory stopping at the first one that isn't empty.
+func removeEmptyDirs(dir, top string) (err error) {
+	for {
+		if sys.Exists(dir) {
+			if e := os.Remove(dir); e != nil {
+				return // not empty
+			}
+		}
+		if dir == top || dir == "/" || dir == "." {
+			return
+		}
+		dir = path.Dir(dir)
+	}
+}
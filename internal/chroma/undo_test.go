@@ -0,0 +1,142 @@
+package chroma
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/phR0ze/n/pkg/sys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUndo(t *testing.T) {
+	c, dir := newTestPackage(t, `version: 1
+patchsets:
+  team:
+    source: dir
+    url: team
+    patches:
+      - name: b.patch
+        enabled: true
+`)
+	defer sys.RemoveAll(dir)
+	writeTestFiles(t, path.Join(dir, "team"), map[string]string{
+		"series":  "a.patch\nb.patch\n",
+		"a.patch": "a",
+		"b.patch": "b",
+	})
+	patchSetDir := path.Join(dir, "patches", "team")
+
+	// Each command is a separate run in the journal
+	c.command = "chroma download patches team"
+	assert.Nil(t, c.downloadPatches([]string{"team"}, &downloadOpts{}))
+	assert.Nil(t, sys.WriteString(path.Join(patchSetDir, "01-b.patch"), "local"))
+	c.journal = nil
+	c.command = "chroma download patches team --clean"
+	assert.Nil(t, c.downloadPatches([]string{"team"}, &downloadOpts{clean: true}))
+	data, _ := sys.ReadString(path.Join(patchSetDir, "01-b.patch"))
+	assert.Equal(t, "b", data)
+
+	// Undoing the clean restores the removed directory from the trash
+	assert.Nil(t, c.undo())
+	data, _ = sys.ReadString(path.Join(patchSetDir, "01-b.patch"))
+	assert.Equal(t, "local", data)
+	assert.True(t, sys.Exists(path.Join(patchSetDir, "not-used/00-a.patch")))
+	assert.True(t, sys.Exists(path.Join(patchSetDir, SyncStateName)))
+
+	// Undoing the first download removes the downloaded patches
+	assert.Nil(t, c.undo())
+	assert.False(t, sys.Exists(path.Join(patchSetDir, "01-b.patch")))
+	assert.False(t, sys.Exists(path.Join(patchSetDir, "not-used")))
+	assert.False(t, sys.Exists(path.Join(patchSetDir, SyncStateName)))
+	assert.Equal(t, "nothing to undo", c.undo().Error())
+}
+
+func TestUndoCacheFiles(t *testing.T) {
+	series := "a.patch\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path.Base(r.URL.Path) == "series" {
+			fmt.Fprint(w, series)
+			return
+		}
+		fmt.Fprint(w, path.Base(r.URL.Path))
+	}))
+	defer server.Close()
+	c, dir := newTestPackage(t, fmt.Sprintf(`version: 1
+patchsets:
+  team:
+    source: quilt
+    url: %s/series
+    rules:
+      - match: "*"
+        enabled: true
+`, server.URL))
+	defer sys.RemoveAll(dir)
+	patchSetDir := path.Join(dir, "patches", "team")
+
+	// Cache files are planned when making no changes
+	c.dryrun = true
+	assert.Nil(t, c.downloadPatches([]string{"team"}, &downloadOpts{}))
+	descriptions := []string{}
+	for _, action := range c.plan {
+		descriptions = append(descriptions, c.describe(action))
+	}
+	assert.Contains(t, descriptions, "write patches/team/series")
+	assert.Contains(t, descriptions, "write patches/team/.sync.json")
+	assert.False(t, sys.Exists(patchSetDir))
+
+	// Undoing a refresh restores the previous series and sync state
+	c.dryrun, c.plan, c.planned = false, nil, nil
+	c.command = "chroma download patches team"
+	assert.Nil(t, c.downloadPatches([]string{"team"}, &downloadOpts{}))
+	before, _ := sys.ReadString(path.Join(patchSetDir, SyncStateName))
+	series = "a.patch\nb.patch\n"
+	c.journal = nil
+	c.command = "chroma download patches team --refresh"
+	assert.Nil(t, c.downloadPatches([]string{"team"}, &downloadOpts{refresh: true}))
+	data, _ := sys.ReadString(path.Join(patchSetDir, "series"))
+	assert.Equal(t, "a.patch\nb.patch\n", data)
+
+	assert.Nil(t, c.undo())
+	data, _ = sys.ReadString(path.Join(patchSetDir, "series"))
+	assert.Equal(t, "a.patch\n", data)
+	data, _ = sys.ReadString(path.Join(patchSetDir, SyncStateName))
+	assert.Equal(t, before, data)
+}
+
+func TestUndoNested(t *testing.T) {
+	c, dir := newTestPackage(t, "")
+	defer sys.RemoveAll(dir)
+	outer, inner := path.Join(dir, "outer"), path.Join(dir, "inner")
+	writeTestFiles(t, dir, map[string]string{"outer": "old outer", "inner": "old inner"})
+
+	// Actions performed while recording another are both journaled
+	c.command = "chroma nested"
+	assert.Nil(t, c.perform(&Action{Kind: ActionWrite, Dst: outer}, func() error {
+		if err := c.write(inner, []byte("new inner")); err != nil {
+			return err
+		}
+		return sys.WriteString(outer, "new outer")
+	}))
+	j, err := loadJournal(dir)
+	assert.Nil(t, err)
+	entries := j.Runs[0].Entries
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, inner, entries[0].Action.Dst)
+	assert.Equal(t, outer, entries[1].Action.Dst)
+
+	assert.Nil(t, c.undo())
+	data, _ := sys.ReadString(outer)
+	assert.Equal(t, "old outer", data)
+	data, _ = sys.ReadString(inner)
+	assert.Equal(t, "old inner", data)
+
+	// Malformed entries are skipped
+	writeTestFiles(t, dir, map[string]string{
+		path.Join(JournalDir, JournalName): `{"runs": [{"id": "1", "command": "chroma bad", "entries": [null, {"action": null}]}]}`,
+	})
+	assert.Nil(t, c.undo())
+	assert.Equal(t, "nothing to undo", c.undo().Error())
+}